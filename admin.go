@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+// AdminStatus is what the admin API responds with for GET /v1/status
+type AdminStatus struct {
+	Target target.TargetType `json:"target"`
+	Paths  []PathStatus      `json:"paths"`
+}
+
+// adminHandler serves the admin API meant for incident response. It isn't authenticated, hence, it's only ever
+// served on a loopback address or a unix socket, see listenAdmin.
+func adminHandler(p *Poller) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			respondAdmin(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s isn't allowed", r.Method))
+			return
+		}
+		respondAdmin(w, http.StatusOK, AdminStatus{Target: p.TargetType, Paths: p.State.Statuses()})
+	})
+	mux.HandleFunc("/v1/repoll", adminAction(false, func(r *http.Request, _ string) error {
+		return p.Repoll(r.Context())
+	}))
+	mux.HandleFunc("/v1/redeliver", adminAction(true, func(r *http.Request, path string) error {
+		return p.Redeliver(r.Context(), path)
+	}))
+	mux.HandleFunc("/v1/pause", adminAction(true, func(_ *http.Request, path string) error {
+		return p.Pause(path)
+	}))
+	mux.HandleFunc("/v1/resume", adminAction(true, func(_ *http.Request, path string) error {
+		return p.Resume(path)
+	}))
+	return mux
+}
+
+// adminAction serves a POST request running the action, for the path in the 'path' query parameter if needed
+func adminAction(needsPath bool, action func(r *http.Request, path string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			respondAdmin(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s isn't allowed", r.Method))
+			return
+		}
+		path := r.URL.Query().Get("path")
+		if needsPath && path == "" {
+			respondAdmin(w, http.StatusBadRequest, errors.New("no path found to be provided in the 'path' query parameter"))
+			return
+		}
+		err := action(r, path)
+		switch {
+		case err == nil:
+			respondAdmin(w, http.StatusOK, map[string]string{"result": "ok"})
+		case errors.Is(err, errUnknownPath):
+			respondAdmin(w, http.StatusNotFound, fmt.Errorf("path '%s' isn't watched", path))
+		case errors.Is(err, errNoEvent):
+			respondAdmin(w, http.StatusConflict, fmt.Errorf("no event has been delivered for the path '%s' yet", path))
+		default:
+			respondAdmin(w, http.StatusBadGateway, err)
+		}
+	}
+}
+
+func respondAdmin(w http.ResponseWriter, status int, body interface{}) {
+	if err, ok := body.(error); ok {
+		body = map[string]string{"error": err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.WithError(err).Debug("failed to write the response of the admin API")
+	}
+}
+
+// listenAdmin listens on the unix socket of an address like 'unix:/run/vaultie-talkie/admin.sock', otherwise on the
+// TCP address, which must be a loopback one
+func listenAdmin(address string) (net.Listener, error) {
+	if socket := strings.TrimPrefix(address, "unix:"); socket != address {
+		// a socket left behind by a previous run would fail the listening
+		if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("error occurred while removing the stale unix socket '%s': %w", socket, err)
+		}
+		listener, err := net.Listen("unix", socket)
+		if err != nil {
+			return nil, fmt.Errorf("error occurred while listening on the unix socket '%s': %w", socket, err)
+		}
+		if err := os.Chmod(socket, 0600); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("error occurred while restricting the permissions of the unix socket '%s': %w", socket, err)
+		}
+		return listener, nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while parsing the admin address '%s': %w", address, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("admin API can only be served on a loopback address or a unix socket, found '%s'", address)
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while listening on the address '%s': %w", address, err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+// mockVault serves the KV v2 secrets of the paths, like a vault store would
+type mockVault struct {
+	mu       sync.Mutex
+	secrets  map[string]map[string]interface{}
+	versions map[string]int
+	sv       *httptest.Server
+	// delay of every read, along with the reads in progress and the most of them at once
+	delay      time.Duration
+	reading    int
+	maxReading int
+	reads      []string
+	// lookups of the token's time to live
+	tokenLookups int
+}
+
+func (m *mockVault) setup(t *testing.T) *vault.Client {
+	m.secrets = map[string]map[string]interface{}{}
+	m.versions = map[string]int{}
+	m.sv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")
+		m.mu.Lock()
+		m.reading++
+		if m.reading > m.maxReading {
+			m.maxReading = m.reading
+		}
+		if path != r.URL.Path {
+			m.reads = append(m.reads, path)
+		}
+		if r.URL.Path == "/v1/auth/token/lookup-self" {
+			m.tokenLookups++
+		}
+		delay := m.delay
+		m.mu.Unlock()
+		time.Sleep(delay)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.reading--
+		secret, ok := m.secrets[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+			"data":     secret,
+			"metadata": map[string]interface{}{"version": m.versions[path], "custom_metadata": nil},
+		}})
+	}))
+	config := vault.DefaultConfig()
+	config.Address = m.sv.URL
+	config.MaxRetries = 0
+	client, err := vault.NewClient(config)
+	assert.NoError(t, err)
+	return client
+}
+
+func (m *mockVault) put(path string, secret map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.secrets[path] = secret
+	m.versions[path]++
+}
+
+func (m *mockVault) read() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string{}, m.reads...)
+}
+
+func (m *mockVault) teardown() {
+	m.sv.Close()
+}
+
+// mockTarget records the events it gets executed for, failing with err if set
+type mockTarget struct {
+	mu     sync.Mutex
+	events []target.Event
+	err    error
+}
+
+func (m *mockTarget) Args() {}
+
+func (m *mockTarget) Execute(oldKeyStore, newKeyStore target.KeyStore) error {
+	return m.ExecuteEvent(target.Event{OldKeyStore: oldKeyStore, NewKeyStore: newKeyStore})
+}
+
+func (m *mockTarget) ExecuteEvent(event target.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event)
+	return m.err
+}
+
+func (m *mockTarget) setErr(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err = err
+}
+
+func (m *mockTarget) executed() []target.Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]target.Event{}, m.events...)
+}
+
+// runPoller runs a poller which only ever polls when asked to, through the admin API
+func runPoller(t *testing.T, client *vault.Client, tg target.Target, paths ...string) (*Poller, *httptest.Server, func()) {
+	poller := NewPoller(client, tg, "mock", time.Hour, paths, -1, 0)
+	stopPoller := startPoller(t, poller)
+	sv := httptest.NewServer(adminHandler(poller))
+	return poller, sv, func() {
+		sv.Close()
+		stopPoller()
+	}
+}
+
+// startPoller runs the poller until the returned function stops it
+func startPoller(t *testing.T, poller *Poller) func() {
+	exit := make(chan os.Signal, 1)
+	done := make(chan error)
+	go func() { done <- poller.Run(exit) }()
+	return func() {
+		exit <- os.Interrupt
+		assert.NoError(t, <-done)
+	}
+}
+
+func adminRequest(t *testing.T, method, url string, response interface{}) int {
+	req, err := http.NewRequest(method, url, nil)
+	assert.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	if response != nil {
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(response))
+	}
+	return resp.StatusCode
+}
+
+func TestAdminStatusAndRepoll(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	mv.put("app", map[string]interface{}{"password": "foo"})
+	tg := &mockTarget{}
+	_, sv, stop := runPoller(t, client, tg, "app", "other")
+	defer stop()
+
+	status := AdminStatus{}
+	assert.Equal(t, http.StatusOK, adminRequest(t, http.MethodGet, sv.URL+"/v1/status", &status))
+	assert.Equal(t, target.TargetType("mock"), status.Target)
+	assert.Equal(t, []PathStatus{{Path: "app"}, {Path: "other"}}, status.Paths)
+
+	assert.Equal(t, http.StatusOK, adminRequest(t, http.MethodPost, sv.URL+"/v1/repoll", nil))
+	assert.Len(t, tg.executed(), 1)
+
+	assert.Equal(t, http.StatusOK, adminRequest(t, http.MethodGet, sv.URL+"/v1/status", &status))
+	app := status.Paths[0]
+	assert.Equal(t, 1, app.Version)
+	hash, err := keyStoreHash(target.KeyStore{"password": "foo"})
+	assert.NoError(t, err)
+	assert.Equal(t, hash, app.Hash)
+	assert.NotNil(t, app.LastPolledAt)
+	if assert.NotNil(t, app.LastResult) {
+		assert.Equal(t, tg.executed()[0].ID, app.LastResult.EventId)
+		assert.Equal(t, 1, app.LastResult.Attempt)
+		assert.Equal(t, "success", string(app.LastResult.Outcome))
+	}
+	// the values of the secrets never get exposed
+	body, err := json.Marshal(status)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(body), "foo")
+
+	// reading the missing path failed, hence, it was never polled
+	assert.Nil(t, status.Paths[1].LastPolledAt)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, adminRequest(t, http.MethodGet, sv.URL+"/v1/repoll", nil))
+}
+
+func TestAdminRedeliver(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	mv.put("app", map[string]interface{}{"password": "foo"})
+	tg := &mockTarget{}
+	_, sv, stop := runPoller(t, client, tg, "app")
+	defer stop()
+
+	response := map[string]string{}
+	assert.Equal(t, http.StatusConflict, adminRequest(t, http.MethodPost, sv.URL+"/v1/redeliver?path=app", &response))
+	assert.Equal(t, "no event has been delivered for the path 'app' yet", response["error"])
+	assert.Equal(t, http.StatusNotFound, adminRequest(t, http.MethodPost, sv.URL+"/v1/redeliver?path=unknown", nil))
+	assert.Equal(t, http.StatusBadRequest, adminRequest(t, http.MethodPost, sv.URL+"/v1/redeliver", nil))
+
+	tg.setErr(errors.New("target is down"))
+	assert.Equal(t, http.StatusOK, adminRequest(t, http.MethodPost, sv.URL+"/v1/repoll", nil))
+
+	// a failing re-delivery is reported back
+	response = map[string]string{}
+	assert.Equal(t, http.StatusBadGateway, adminRequest(t, http.MethodPost, sv.URL+"/v1/redeliver?path=app", &response))
+	assert.Contains(t, response["error"], "target is down")
+
+	// a succeeding re-delivery of the pending change delivers it, so that polling doesn't execute the target again
+	tg.setErr(nil)
+	assert.Equal(t, http.StatusOK, adminRequest(t, http.MethodPost, sv.URL+"/v1/redeliver?path=app", nil))
+	assert.Equal(t, http.StatusOK, adminRequest(t, http.MethodPost, sv.URL+"/v1/repoll", nil))
+	events := tg.executed()
+	if assert.Len(t, events, 3) {
+		for i, event := range events {
+			assert.Equal(t, events[0].ID, event.ID)
+			assert.Equal(t, i+1, event.Attempt)
+			assert.Equal(t, target.KeyStore{"password": "foo"}, event.NewKeyStore)
+		}
+	}
+
+	// the last event can be re-delivered even once it got delivered
+	assert.Equal(t, http.StatusOK, adminRequest(t, http.MethodPost, sv.URL+"/v1/redeliver?path=app", nil))
+	events = tg.executed()
+	if assert.Len(t, events, 4) {
+		assert.Equal(t, events[0].ID, events[3].ID)
+		assert.Equal(t, 4, events[3].Attempt)
+	}
+}
+
+func TestAdminPauseAndResume(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	mv.put("app", map[string]interface{}{"password": "foo"})
+	tg := &mockTarget{}
+	poller, sv, stop := runPoller(t, client, tg, "app")
+	defer stop()
+
+	assert.Equal(t, http.StatusOK, adminRequest(t, http.MethodPost, sv.URL+"/v1/pause?path=app", nil))
+	assert.True(t, poller.State.Statuses()[0].Paused)
+	assert.Equal(t, http.StatusOK, adminRequest(t, http.MethodPost, sv.URL+"/v1/repoll", nil))
+	assert.Empty(t, tg.executed())
+	assert.Nil(t, poller.State.Statuses()[0].LastPolledAt)
+
+	assert.Equal(t, http.StatusOK, adminRequest(t, http.MethodPost, sv.URL+"/v1/resume?path=app", nil))
+	assert.False(t, poller.State.Statuses()[0].Paused)
+	assert.Equal(t, http.StatusOK, adminRequest(t, http.MethodPost, sv.URL+"/v1/repoll", nil))
+	assert.Len(t, tg.executed(), 1)
+
+	assert.Equal(t, http.StatusNotFound, adminRequest(t, http.MethodPost, sv.URL+"/v1/pause?path=unknown", nil))
+}
+
+func TestListenAdmin(t *testing.T) {
+	for _, address := range []string{":0", "0.0.0.0:0", "example.com:8081"} {
+		_, err := listenAdmin(address)
+		assert.EqualError(t, err, fmt.Sprintf("admin API can only be served on a loopback address or a unix socket, found '%s'", address))
+	}
+	for _, address := range []string{"localhost:0", "127.0.0.1:0", "[::1]:0"} {
+		listener, err := listenAdmin(address)
+		if err == nil {
+			listener.Close()
+		} else {
+			assert.Contains(t, err.Error(), "error occurred while listening", address)
+		}
+	}
+
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	// a stale socket gets replaced
+	assert.NoError(t, os.WriteFile(socket, nil, 0600))
+	listener, err := listenAdmin("unix:" + socket)
+	if assert.NoError(t, err) {
+		defer listener.Close()
+		info, err := os.Stat(socket)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+}
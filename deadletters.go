@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/outbox"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+// deadLetter is how a dead letter is shown, with the values of its key stores only if asked for
+type deadLetter struct {
+	ID          string          `json:"id"`
+	Path        string          `json:"path"`
+	Version     int             `json:"version"`
+	DetectedAt  time.Time       `json:"detected_at"`
+	Attempts    int             `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	Added       []string        `json:"added,omitempty"`
+	Removed     []string        `json:"removed,omitempty"`
+	Changed     []string        `json:"changed,omitempty"`
+	OldKeyStore target.KeyStore `json:"old_key_store,omitempty"`
+	NewKeyStore target.KeyStore `json:"new_key_store,omitempty"`
+}
+
+// deadLetters lists, shows or redrives the changes the target got skipped for, as kept under the -outbox-dir of the poller
+func deadLetters(args []string) error {
+	flags := flag.NewFlagSet("dead-letters", flag.ContinueOnError)
+	dir := flags.String("outbox-dir", "", "Outbox directory of the poller")
+	show := flags.String("show", "", "ID of the dead letter to show the details of, instead of listing them all")
+	showValues := flags.Bool("show-values", false, "Show the values of the old and new key stores of the dead letter as well. Beware, these are the secrets")
+	redrive := flags.String("redrive", "", "ID of the dead letter to move back to the pending changes, or 'all'. The running poller picks them up as soon as it reads any watched path. Dead letters of a path which newer changes got delivered for since aren't redriven, as they'd roll the target back to stale secrets")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return fmt.Errorf("no outbox directory found to be provided")
+	}
+	if _, err := os.Stat(*dir); err != nil {
+		return fmt.Errorf("error occurred while opening the outbox directory '%s': %w", *dir, err)
+	}
+	o, err := outbox.NewDir(*dir)
+	if err != nil {
+		return err
+	}
+	dead, err := o.DeadLetters()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *redrive == "all":
+		redriven := 0
+		for _, entry := range dead {
+			_, err := o.Redrive(entry.ID)
+			if errors.Is(err, outbox.ErrSuperseded) {
+				fmt.Fprintf(os.Stdout, "skipped the dead letter %s: %s\n", entry.ID, err)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("error occurred while redriving the dead letter '%s': %w", entry.ID, err)
+			}
+			redriven++
+		}
+		fmt.Fprintf(os.Stdout, "redrove %d dead letters\n", redriven)
+	case *redrive != "":
+		if _, err := o.Redrive(*redrive); err != nil {
+			return fmt.Errorf("error occurred while redriving the dead letter '%s': %w", *redrive, err)
+		}
+		fmt.Fprintf(os.Stdout, "redrove the dead letter %s\n", *redrive)
+	case *show != "":
+		for _, entry := range dead {
+			if entry.ID != *show {
+				continue
+			}
+			diff := target.ComputeDiff(entry.OldKeyStore, entry.NewKeyStore)
+			shown := deadLetter{
+				ID: entry.ID, Path: entry.Path, Version: entry.Version, DetectedAt: entry.DetectedAt, Attempts: entry.Attempts, LastError: entry.LastError,
+				Added: diff.Added, Removed: diff.Removed, Changed: diff.Changed,
+			}
+			if *showValues {
+				shown.OldKeyStore = entry.OldKeyStore
+				shown.NewKeyStore = entry.NewKeyStore
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(shown)
+		}
+		return fmt.Errorf("error occurred while showing the dead letter '%s': %w", *show, outbox.ErrNotFound)
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tPATH\tVERSION\tATTEMPTS\tDETECTED AT\tLAST ERROR")
+		for _, entry := range dead {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\n", entry.ID, entry.Path, entry.Version, entry.Attempts, entry.DetectedAt.Format(time.RFC3339), entry.LastError)
+		}
+		return w.Flush()
+	}
+	return nil
+}
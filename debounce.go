@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/metrics"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/outbox"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+// coalescing is a burst of changes of a path held back until it settles, as a single change from the
+// old key store of the first change to the new key store of the last one
+type coalescing struct {
+	entry   outbox.Entry
+	changes int
+	lastAt  time.Time
+	// the read of the last change, for its trace
+	read timespan
+}
+
+// coalesce holds the change back along with the earlier ones of its path, if any
+func (p *Poller) coalesce(entry outbox.Entry, read timespan) {
+	logger := log.WithFields(log.Fields{"path": entry.Path, "version": entry.Version})
+	c, ok := p.coalescing[entry.Path]
+	if !ok {
+		c = &coalescing{entry: entry}
+		p.coalescing[entry.Path] = c
+		logger.Info("change observed between the old and new key store, holding it back for the debounce window")
+	} else {
+		c.entry.Version = entry.Version
+		c.entry.NewKeyStore = entry.NewKeyStore
+		metrics.ChangesCoalesced.WithLabelValues(entry.Path).Inc()
+		logger.Info("change observed between the old and new key store, coalescing it with the ones held back")
+	}
+	c.changes++
+	c.lastAt = entry.DetectedAt
+	c.read = read
+	p.State.setCoalescing(entry.Path, c.changes)
+	p.schedule(p.settlesAt(c))
+}
+
+// settlesAt is when the burst of changes is queued, once the path didn't change for the debounce window,
+// or at the latest once the maximum wait passed since the first change
+func (p *Poller) settlesAt(c *coalescing) time.Time {
+	at := c.lastAt.Add(p.Debounce)
+	if latest := c.entry.DetectedAt.Add(p.DebounceMaxWait); latest.Before(at) {
+		return latest
+	}
+	return at
+}
+
+// flush queues the bursts of changes which settled, and schedules the others
+func (p *Poller) flush() {
+	for _, path := range p.Paths {
+		c, ok := p.coalescing[path]
+		if !ok {
+			continue
+		}
+		if at := p.settlesAt(c); time.Now().Before(at) {
+			p.schedule(at)
+			continue
+		}
+		logger := log.WithFields(log.Fields{"path": path, "version": c.entry.Version, "coalesced": c.changes})
+		diffStart := time.Now()
+		if reflect.DeepEqual(c.entry.OldKeyStore, c.entry.NewKeyStore) {
+			logger.Info("changes got reverted within the debounce window, nothing to deliver")
+			delete(p.coalescing, path)
+			p.State.setCoalescing(path, 0)
+			continue
+		}
+		diff := target.ComputeDiff(c.entry.OldKeyStore, c.entry.NewKeyStore)
+		diffed := timespan{diffStart, time.Now()}
+		if err := p.enqueue(&c.entry, c.read, diffed, diff); err != nil {
+			logger.WithError(err).Error("failed to queue the coalesced changes for the target, retrying later")
+			p.schedule(time.Now().Add(p.DeliveryBackoff))
+			continue
+		}
+		logger.WithField("event_id", c.entry.ID).Info("queued the coalesced changes for the target")
+		p.oldKeyStores[path] = c.entry.NewKeyStore
+		delete(p.coalescing, path)
+		p.State.setCoalescing(path, 0)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/outbox"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+func TestDebounceCoalescesBursts(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	tg := &mockTarget{}
+	poller := NewPoller(client, tg, "mock", time.Hour, []string{"app"}, -1, 0)
+	poller.Debounce = 100 * time.Millisecond
+	defer startPoller(t, poller)()
+
+	for _, password := range []string{"foo", "bar", "baz"} {
+		mv.put("app", map[string]interface{}{"password": password})
+		assert.NoError(t, poller.Repoll(context.Background()))
+	}
+	assert.Empty(t, tg.executed())
+	assert.Equal(t, 3, poller.State.Statuses()[0].Coalescing)
+
+	assert.Eventually(t, func() bool { return len(tg.executed()) == 1 }, 5*time.Second, 5*time.Millisecond)
+	event := tg.executed()[0]
+	assert.Equal(t, target.KeyStore{}, event.OldKeyStore)
+	assert.Equal(t, target.KeyStore{"password": "baz"}, event.NewKeyStore)
+	assert.Equal(t, 3, event.Version)
+	assert.Equal(t, 0, poller.State.Statuses()[0].Coalescing)
+
+	// the next burst starts from where the last one ended
+	mv.put("app", map[string]interface{}{"password": "qux"})
+	assert.NoError(t, poller.Repoll(context.Background()))
+	assert.Eventually(t, func() bool { return len(tg.executed()) == 2 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, target.KeyStore{"password": "baz"}, tg.executed()[1].OldKeyStore)
+}
+
+func TestDebounceMaxWait(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	tg := &mockTarget{}
+	poller := NewPoller(client, tg, "mock", time.Hour, []string{"app"}, -1, 0)
+	poller.Debounce = time.Hour
+	poller.DebounceMaxWait = 100 * time.Millisecond
+	defer startPoller(t, poller)()
+
+	mv.put("app", map[string]interface{}{"password": "foo"})
+	assert.NoError(t, poller.Repoll(context.Background()))
+	assert.Eventually(t, func() bool { return len(tg.executed()) == 1 }, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestDebounceDropsRevertedChanges(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	mv.put("app", map[string]interface{}{"password": "foo"})
+	tg := &mockTarget{}
+	poller := NewPoller(client, tg, "mock", time.Hour, []string{"app"}, -1, 0)
+	poller.Debounce = 100 * time.Millisecond
+	defer startPoller(t, poller)()
+	assert.NoError(t, poller.Repoll(context.Background()))
+	assert.Eventually(t, func() bool { return len(tg.executed()) == 1 }, 5*time.Second, 5*time.Millisecond)
+
+	// a rotation rolled back within the window delivers nothing
+	for _, password := range []string{"bar", "foo"} {
+		mv.put("app", map[string]interface{}{"password": password})
+		assert.NoError(t, poller.Repoll(context.Background()))
+	}
+	assert.Equal(t, 2, poller.State.Statuses()[0].Coalescing)
+	assert.Eventually(t, func() bool { return poller.State.Statuses()[0].Coalescing == 0 }, 5*time.Second, 5*time.Millisecond)
+	assert.Len(t, tg.executed(), 1)
+}
+
+func TestDebounceSurvivesRestarts(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	mv.put("app", map[string]interface{}{"password": "foo"})
+	dir := t.TempDir()
+	o, err := outbox.NewDir(dir)
+	assert.NoError(t, err)
+	tg := &mockTarget{}
+	poller := NewPoller(client, tg, "mock", time.Hour, []string{"app"}, -1, 0)
+	poller.Outbox = o
+	poller.Debounce = time.Hour
+	stop := startPoller(t, poller)
+	assert.NoError(t, poller.Repoll(context.Background()))
+	assert.Equal(t, 1, poller.State.Statuses()[0].Coalescing)
+	stop()
+
+	// the change held back when stopping is detected again by the next run
+	o, err = outbox.NewDir(dir)
+	assert.NoError(t, err)
+	poller = NewPoller(client, tg, "mock", time.Hour, []string{"app"}, -1, 0)
+	poller.Outbox = o
+	defer startPoller(t, poller)()
+	assert.NoError(t, poller.Repoll(context.Background()))
+	if assert.Len(t, tg.executed(), 1) {
+		assert.Equal(t, target.KeyStore{"password": "foo"}, tg.executed()[0].NewKeyStore)
+	}
+}
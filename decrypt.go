@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/encryption"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/fileutil"
+)
+
+// decrypt prints, or writes to a file, the decrypted contents of a file encrypted by the "file" or "command" target-types
+func decrypt(args []string) error {
+	flags := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	in := flags.String("in", "", "Path to the encrypted file")
+	out := flags.String("out", "", "Path to write the decrypted contents to, with 0600 permissions. Defaults to the standard output")
+	privateKeyFile := flags.String("private-key-file", "", "'nacl' scheme only. Path to the file holding the base64 encoded private key, as generated by the 'keygen' subcommand")
+	vaultSettings := VaultSettings{}
+	flags.StringVar(&vaultSettings.Host, "vault-host", "", "'transit' scheme only. Host of the vault store")
+	flags.Int64Var(&vaultSettings.Port, "vault-port", 8200, "'transit' scheme only. Port at which the vault store is running")
+	flags.StringVar(&vaultSettings.AccessToken, "vault-access-token", "", "'transit' scheme only. Access token authorizing to decrypt with the transit key")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" {
+		return fmt.Errorf("no encrypted file path found to be provided")
+	}
+
+	encrypted, err := os.ReadFile(*in)
+	if err != nil {
+		return fmt.Errorf("error occurred while reading the encrypted file at the path '%s': %w", *in, err)
+	}
+	var privateKey *[32]byte
+	if *privateKeyFile != "" {
+		encoded, err := os.ReadFile(*privateKeyFile)
+		if err != nil {
+			return fmt.Errorf("error occurred while reading the private key file at the path '%s': %w", *privateKeyFile, err)
+		}
+		if privateKey, err = encryption.ParseKey(string(encoded)); err != nil {
+			return err
+		}
+	}
+	var vaultClient *vault.Client
+	if vaultSettings.Host != "" {
+		if vaultClient, err = vaultSettings.InitClient(); err != nil {
+			return err
+		}
+	}
+
+	plaintext, err := encryption.Decrypt(encrypted, privateKey, vaultClient)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(plaintext)
+		return err
+	}
+	return fileutil.WriteAtomic(*out, plaintext, fileutil.DefaultWriteOptions(0600))
+}
+
+// keygen generates a key pair for the 'nacl' encryption scheme, printing the public key and saving the private one
+func keygen(args []string) error {
+	flags := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	privateKeyFile := flags.String("private-key-file", "", "Path to write the base64 encoded private key to, with 0600 permissions")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *privateKeyFile == "" {
+		return fmt.Errorf("no private key file path found to be provided")
+	}
+	if _, err := os.Stat(*privateKeyFile); err == nil {
+		return fmt.Errorf("refusing to overwrite the existing private key file at the path '%s'", *privateKeyFile)
+	}
+
+	publicKey, privateKey, err := encryption.GenerateKey()
+	if err != nil {
+		return err
+	}
+	if err := fileutil.WriteAtomic(*privateKeyFile, []byte(privateKey+"\n"), fileutil.DefaultWriteOptions(0600)); err != nil {
+		return fmt.Errorf("error occurred while writing the private key file at the path '%s': %w", *privateKeyFile, err)
+	}
+	fmt.Fprintln(os.Stdout, publicKey)
+	return nil
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/metrics"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/outbox"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// deliveryPass goes through the pending changes of every path which isn't paused, in the order they were detected,
+// and executes the target for the ones due, or forced to be, one at a time. A path is held up by its oldest pending
+// change until that one gets delivered or skipped.
+type deliveryPass struct {
+	force bool
+	// the paths held up by a change which isn't due or which the target just failed for
+	held map[string]bool
+	// the changes the target got executed for already, so that none is delivered twice by the pass
+	attempted map[uint64]bool
+	// the re-polls waiting for the pass to be over
+	replies []chan error
+}
+
+// execution is an execution of the target in the background, for a pending change or a re-delivery
+type execution struct {
+	ctx   context.Context
+	event target.Event
+	// the pending change the target is executed for, if any
+	entry   outbox.Entry
+	pending bool
+	// replies to the re-delivery, nil for the changes of a delivery pass
+	redelivery chan error
+	span       trace.Span
+	duration   time.Duration
+	err        error
+}
+
+// deliver has the pending changes delivered by a pass of their own, right after the one going on if any, replying
+// to the re-polls once it's over. Nothing gets delivered before every path got read.
+func (p *Poller) deliver(force bool, replies ...chan error) {
+	if p.nextPass == nil {
+		p.nextPass = &deliveryPass{held: map[string]bool{}, attempted: map[uint64]bool{}}
+	}
+	p.nextPass.force = p.nextPass.force || force
+	p.nextPass.replies = append(p.nextPass.replies, replies...)
+	p.next()
+}
+
+// next starts the next execution of the target, unless one is going on already: the re-deliveries first, then
+// the changes of the delivery pass
+func (p *Poller) next() {
+	for p.executing == nil {
+		if len(p.redeliveryQueue) > 0 {
+			r := p.redeliveryQueue[0]
+			p.redeliveryQueue = p.redeliveryQueue[1:]
+			p.redeliver(r)
+			continue
+		}
+		if p.pass == nil {
+			if p.nextPass == nil || !p.everyPathRead() {
+				return
+			}
+			p.pass, p.nextPass = p.nextPass, nil
+			p.nextDelivery = time.Time{}
+			// queues the bursts of changes which settled
+			p.flush()
+		}
+		if !p.deliverNext() {
+			p.passDone()
+		}
+	}
+}
+
+// deliverNext records the changes queued of every path, then starts executing the target for the next pending change
+// of the pass, and tells whether there's one
+func (p *Poller) deliverNext() bool {
+	entries, err := p.Outbox.Pending()
+	if err != nil {
+		log.WithError(err).Error("failed to list the pending changes")
+		p.schedule(time.Now().Add(p.DeliveryBackoff))
+		return false
+	}
+	queued := map[string]int{}
+	for _, entry := range entries {
+		queued[entry.Path]++
+	}
+	for _, path := range p.Paths {
+		metrics.QueuedChanges.WithLabelValues(path).Set(float64(queued[path]))
+		p.State.setQueued(path, queued[path])
+	}
+	for _, entry := range entries {
+		path := entry.Path
+		switch {
+		case p.pass.held[path] || p.pass.attempted[entry.Seq]:
+			continue
+		case !p.State.watches(path):
+			// left pending by a previous run watching other paths
+			entry.LastError = "path isn't watched anymore"
+			p.bury(log.WithField("path", path), entry)
+			continue
+		case p.State.paused(path):
+			p.pass.held[path] = true
+			continue
+		case !p.pass.force && time.Now().Before(entry.NextAttemptAt):
+			p.pass.held[path] = true
+			p.schedule(entry.NextAttemptAt)
+			continue
+		}
+		p.pass.attempted[entry.Seq] = true
+		entry.Attempts++
+		event := p.event(entry)
+		log.WithFields(event.Fields()).WithField("version", event.Version).Info("executing the target")
+		p.start(&execution{
+			ctx:     tracing.WithTraceParent(context.Background(), entry.TraceParent),
+			event:   event,
+			entry:   entry,
+			pending: true,
+		})
+		return true
+	}
+	return false
+}
+
+// passDone replies to the re-polls waiting for the pass
+func (p *Poller) passDone() {
+	for _, reply := range p.pass.replies {
+		reply <- nil
+	}
+	p.pass = nil
+}
+
+// start executes the target in the background, so that the poller loop keeps polling meanwhile. The outcome is
+// handed back to the loop through the executions channel.
+func (p *Poller) start(x *execution) {
+	p.executing = x
+	go func() {
+		x.duration, x.err = p.run(x.ctx, x.event)
+		p.executions <- x
+	}()
+}
+
+// executed takes in the outcome of the execution of the target
+func (p *Poller) executed(x *execution) {
+	p.executing = nil
+	p.record(x.event, x.duration, x.err)
+	if x.redelivery != nil {
+		tracing.End(x.span, x.err)
+		x.redelivery <- p.redelivered(x)
+		return
+	}
+	if !p.deliveredEntry(x) {
+		p.pass.held[x.entry.Path] = true
+	}
+}
+
+// awaitExecution waits for the execution of the target going on, if any, and takes its outcome in
+func (p *Poller) awaitExecution() {
+	if p.executing == nil {
+		return
+	}
+	log.Info("waiting for the execution of the target going on to finish")
+	p.executed(<-p.executions)
+}
+
+// deliveredEntry handles the outcome of executing the target for the pending change, and tells whether it's done
+// with, that is, delivered or skipped
+func (p *Poller) deliveredEntry(x *execution) bool {
+	entry, err := x.entry, x.err
+	logger := log.WithFields(x.event.Fields()).WithField("version", x.event.Version).WithField("duration", x.duration.String())
+	if err == nil {
+		logger.Info("target executed successfully")
+		p.delivered(logger, entry)
+		return true
+	}
+	entry.LastError = err.Error()
+	if target.IsPermanent(err) {
+		logger.Warn(fmt.Errorf("error occurred while executing the target for the path '%s', not retrying this change: %w", entry.Path, err).Error())
+		p.bury(logger, entry)
+		p.resetTargetFailures(entry.Path)
+		return true
+	}
+	return p.targetFailed(logger, entry, fmt.Errorf("error occurred while executing the target for the path '%s': %w", entry.Path, err))
+}
+
+func (p *Poller) event(entry outbox.Entry) target.Event {
+	return target.Event{
+		ID:          entry.ID,
+		Attempt:     entry.Attempts,
+		Target:      p.TargetType,
+		Path:        entry.Path,
+		Version:     entry.Version,
+		OldKeyStore: entry.OldKeyStore,
+		NewKeyStore: entry.NewKeyStore,
+		KeyStores:   p.keyStores(entry),
+	}
+}
+
+// keyStores returns the latest key stores read of every path, as of when the target gets executed rather than
+// when the change got detected, so that retries don't miss the paths read since
+func (p *Poller) keyStores(entry outbox.Entry) map[string]target.KeyStore {
+	keyStores := map[string]target.KeyStore{}
+	for _, path := range p.Paths {
+		if _, seen := p.oldKeyStores[path]; seen || p.coalescing[path] != nil {
+			keyStores[path] = p.latestKeyStore(path)
+		}
+	}
+	keyStores[entry.Path] = entry.NewKeyStore
+	return keyStores
+}
+
+func (p *Poller) delivered(logger *log.Entry, entry outbox.Entry) {
+	metrics.DeliveryLatency.WithLabelValues(string(p.TargetType)).Observe(time.Since(entry.DetectedAt).Seconds())
+	p.resetTargetFailures(entry.Path)
+	if err := p.Outbox.Delete(entry); err != nil {
+		logger.WithError(err).Error("failed to remove the delivered change from the outbox, the target may get executed for it again")
+	}
+}
+
+// retryLater records the failed attempt and schedules the next one, after a backoff doubling with every attempt
+func (p *Poller) retryLater(logger *log.Entry, entry outbox.Entry) time.Duration {
+	backoff := p.DeliveryBackoff
+	for i := 1; i < entry.Attempts && backoff < p.DeliveryBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > p.DeliveryBackoffMax {
+		backoff = p.DeliveryBackoffMax
+	}
+	entry.NextAttemptAt = time.Now().Add(backoff)
+	p.update(logger, entry)
+	p.schedule(entry.NextAttemptAt)
+	return backoff
+}
+
+func (p *Poller) update(logger *log.Entry, entry outbox.Entry) {
+	if err := p.Outbox.Update(entry); err != nil {
+		logger.WithError(err).Error("failed to record the attempt of the change in the outbox")
+	}
+}
+
+// bury moves the change to the dead letters, from where it can be redriven
+func (p *Poller) bury(logger *log.Entry, entry outbox.Entry) {
+	metrics.ChangesSkipped.WithLabelValues(entry.Path).Inc()
+	if err := p.Outbox.Bury(entry); err != nil {
+		logger.WithError(err).Error("failed to move the skipped change to the dead letters")
+	}
+}
+
+// sharedOutbox tells whether another process, like the 'dead-letters' subcommand, may queue changes in the outbox
+func (p *Poller) sharedOutbox() bool {
+	_, ok := p.Outbox.(*outbox.Dir)
+	return ok
+}
+
+// schedule makes sure that the poller loop delivers again at the time at the latest
+func (p *Poller) schedule(at time.Time) {
+	if p.nextDelivery.IsZero() || at.Before(p.nextDelivery) {
+		p.nextDelivery = at
+	}
+}
+
+// run executes the target for the event
+func (p *Poller) run(ctx context.Context, event target.Event) (time.Duration, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "target.execute", trace.WithAttributes(
+		tracing.TargetKey.String(string(p.TargetType)),
+		tracing.AttemptKey.Int(event.Attempt),
+	))
+	start := time.Now()
+	executed := event
+	executed.Context = ctx
+	err := target.Execute(p.Target, executed)
+	duration := time.Since(start)
+	tracing.End(span, err)
+	return duration, err
+}
+
+// record records the outcome of executing the target for the event
+func (p *Poller) record(event target.Event, duration time.Duration, err error) {
+	p.lastEvents[event.Path] = event
+	result := TargetResult{EventId: event.ID, Attempt: event.Attempt, Outcome: metrics.Success, At: time.Now().Add(-duration), Duration: duration.String()}
+	if err != nil {
+		result.Outcome = metrics.Failure
+		if target.IsPermanent(err) {
+			result.Outcome = metrics.PermanentFailure
+		}
+		result.Error = err.Error()
+	}
+	p.State.setResult(event.Path, result)
+	metrics.TargetExecutionDuration.WithLabelValues(string(p.TargetType)).Observe(duration.Seconds())
+	metrics.TargetExecutions.WithLabelValues(string(p.TargetType), string(result.Outcome)).Inc()
+}
+
+// redeliver starts executing the target again for the last event of the path, or replies right away if there's
+// none. The event keeps its ID, and if it's still pending, succeeding delivers it as if it got retried by the poller.
+// Failures don't count towards the failure budget as it's up to the operator to act on them.
+func (p *Poller) redeliver(r redelivery) {
+	event, ok := p.lastEvents[r.path]
+	if !ok {
+		r.reply <- errNoEvent
+		return
+	}
+	entry, pending := p.pendingEntry(r.path, event.ID)
+	if pending {
+		entry.Attempts++
+		event.Attempt = entry.Attempts
+	} else {
+		event.Attempt++
+	}
+	log.WithFields(event.Fields()).WithField("version", event.Version).Info("re-delivering the last event to the target")
+	ctx, span := tracing.Tracer().Start(tracing.WithTraceParent(context.Background(), entry.TraceParent), "redeliver", trace.WithAttributes(
+		tracing.PathKey.String(event.Path),
+		tracing.EventIdKey.String(event.ID),
+		tracing.AttemptKey.Int(event.Attempt),
+		tracing.VersionKey.Int(event.Version),
+	))
+	p.start(&execution{ctx: ctx, event: event, entry: entry, pending: pending, redelivery: r.reply, span: span})
+}
+
+// redelivered handles the outcome of re-delivering the event, returning the error to reply with
+func (p *Poller) redelivered(x *execution) error {
+	event, entry := x.event, x.entry
+	logger := log.WithFields(event.Fields()).WithField("version", event.Version).WithField("duration", x.duration.String())
+	if x.err != nil {
+		err := fmt.Errorf("error occurred while re-delivering the event '%s' for the path '%s': %w", event.ID, event.Path, x.err)
+		logger.Warn(err.Error())
+		if x.pending {
+			entry.LastError = err.Error()
+			p.update(logger, entry)
+		}
+		return err
+	}
+	logger.Info("event re-delivered successfully")
+	if x.pending {
+		p.delivered(logger, entry)
+		// the changes held up by this one are due now
+		p.schedule(time.Now())
+	}
+	return nil
+}
+
+// pendingEntry returns the oldest pending change of the path if it's the one of the ID
+func (p *Poller) pendingEntry(path, id string) (outbox.Entry, bool) {
+	entries, err := p.Outbox.Pending()
+	if err != nil {
+		log.WithError(err).Error("failed to list the pending changes")
+		return outbox.Entry{}, false
+	}
+	for _, entry := range entries {
+		if entry.Path == path && entry.ID == id {
+			return entry, true
+		}
+		if entry.Path == path {
+			break
+		}
+	}
+	return outbox.Entry{}, false
+}
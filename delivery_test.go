@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/outbox"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+func TestDeliveryKeepsIntermediateChanges(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	tg := &mockTarget{}
+	tg.setErr(errors.New("target is down"))
+	poller, _, stop := runPoller(t, client, tg, "app")
+	defer stop()
+
+	// the secret changes twice while the target is down
+	for _, password := range []string{"foo", "bar", "baz"} {
+		mv.put("app", map[string]interface{}{"password": password})
+		assert.NoError(t, poller.Repoll(context.Background()))
+	}
+	assert.Equal(t, 3, poller.State.Statuses()[0].Queued)
+
+	tg.setErr(nil)
+	assert.NoError(t, poller.Repoll(context.Background()))
+	events := tg.executed()
+	assert.Len(t, events, 6)
+	delivered := events[3:]
+	old := target.KeyStore{}
+	for i, password := range []string{"foo", "bar", "baz"} {
+		assert.Equal(t, old, delivered[i].OldKeyStore)
+		assert.Equal(t, target.KeyStore{"password": password}, delivered[i].NewKeyStore)
+		assert.Equal(t, i+1, delivered[i].Version)
+		old = delivered[i].NewKeyStore
+	}
+	assert.Equal(t, 0, poller.State.Statuses()[0].Queued)
+}
+
+func TestDeliveryRetriesInTheBackground(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	mv.put("app", map[string]interface{}{"password": "foo"})
+	tg := &mockTarget{}
+	tg.setErr(errors.New("target is down"))
+	poller := NewPoller(client, tg, "mock", time.Hour, []string{"app"}, -1, 0)
+	poller.DeliveryBackoff = 10 * time.Millisecond
+	defer startPoller(t, poller)()
+
+	assert.NoError(t, poller.Repoll(context.Background()))
+	tg.setErr(nil)
+	// the retry doesn't wait for the next poll, an hour away
+	assert.Eventually(t, func() bool { return len(tg.executed()) == 2 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, 2, tg.executed()[1].Attempt)
+}
+
+func TestDeliveryOutlivesThePoller(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	mv.put("app", map[string]interface{}{"password": "foo", "port": 5432})
+	dir := t.TempDir()
+
+	o, err := outbox.NewDir(dir)
+	assert.NoError(t, err)
+	failing := &mockTarget{}
+	failing.setErr(errors.New("target is down"))
+	poller := NewPoller(client, failing, "mock", time.Hour, []string{"app"}, -1, 0)
+	poller.Outbox = o
+	exit := make(chan os.Signal, 1)
+	done := make(chan error)
+	go func() { done <- poller.Run(exit) }()
+	assert.NoError(t, poller.Repoll(context.Background()))
+	exit <- os.Interrupt
+	assert.NoError(t, <-done)
+	assert.Len(t, failing.executed(), 1)
+
+	// the next run delivers the change left pending once every path got read, without detecting the secret as
+	// changed from an empty key store again
+	o, err = outbox.NewDir(dir)
+	assert.NoError(t, err)
+	tg := &mockTarget{}
+	poller = NewPoller(client, tg, "mock", time.Hour, []string{"app"}, -1, 0)
+	poller.Outbox = o
+	go func() { done <- poller.Run(exit) }()
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, tg.executed())
+	assert.NoError(t, poller.Repoll(context.Background()))
+	assert.Len(t, tg.executed(), 1)
+	exit <- os.Interrupt
+	assert.NoError(t, <-done)
+	event := tg.executed()[0]
+	assert.Equal(t, failing.executed()[0].ID, event.ID)
+	assert.Equal(t, 2, event.Attempt)
+	pending, err := o.Pending()
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestDeliveryBuriesSkippedChanges(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	mv.put("app", map[string]interface{}{"password": "foo"})
+	tg := &mockTarget{}
+	tg.setErr(target.PermanentError{Err: errors.New("bad request")})
+	poller, _, stop := runPoller(t, client, tg, "app")
+	defer stop()
+
+	assert.NoError(t, poller.Repoll(context.Background()))
+	dead, err := poller.Outbox.DeadLetters()
+	assert.NoError(t, err)
+	if assert.Len(t, dead, 1) {
+		assert.Equal(t, tg.executed()[0].ID, dead[0].ID)
+		assert.Equal(t, 1, dead[0].Attempts)
+		assert.Contains(t, dead[0].LastError, "bad request")
+	}
+
+	// a redriven change gets delivered again
+	tg.setErr(nil)
+	_, err = poller.Outbox.Redrive(dead[0].ID)
+	assert.NoError(t, err)
+	assert.NoError(t, poller.Repoll(context.Background()))
+	events := tg.executed()
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, events[0].ID, events[1].ID)
+		assert.Equal(t, 1, events[1].Attempt)
+	}
+}
+
+func TestDeliveryPicksUpChangesRedrivenByAnotherProcess(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	mv.put("app", map[string]interface{}{"password": "foo"})
+	dir := t.TempDir()
+	o, err := outbox.NewDir(dir)
+	assert.NoError(t, err)
+	tg := &mockTarget{}
+	tg.setErr(target.PermanentError{Err: errors.New("bad request")})
+	poller := NewPoller(client, tg, "mock", 20*time.Millisecond, []string{"app"}, -1, 0)
+	poller.Outbox = o
+	defer startPoller(t, poller)()
+
+	var dead []outbox.Entry
+	assert.Eventually(t, func() bool {
+		dead, err = o.DeadLetters()
+		return err == nil && len(dead) == 1
+	}, 5*time.Second, 5*time.Millisecond)
+
+	// like the dead-letters subcommand does
+	tg.setErr(nil)
+	other, err := outbox.NewDir(dir)
+	assert.NoError(t, err)
+	_, err = other.Redrive(dead[0].ID)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(tg.executed()) == 2 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, dead[0].ID, tg.executed()[1].ID)
+}
+
+// blockingTarget blocks every execution until it's released
+type blockingTarget struct {
+	mockTarget
+	release chan struct{}
+}
+
+func (b *blockingTarget) ExecuteEvent(event target.Event) error {
+	<-b.release
+	return b.mockTarget.ExecuteEvent(event)
+}
+
+func TestDeliveryDoesNotHoldThePollingUp(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	mv.put("app", map[string]interface{}{"password": "foo"})
+	tg := &blockingTarget{release: make(chan struct{})}
+	poller := NewPoller(client, tg, "mock", 20*time.Millisecond, []string{"app"}, -1, 0)
+	defer startPoller(t, poller)()
+
+	// the path keeps being read while the target is stuck on its change
+	assert.Eventually(t, func() bool { return len(mv.read()) >= 5 }, 5*time.Second, 5*time.Millisecond)
+	assert.Empty(t, tg.executed())
+	assert.Equal(t, 1, poller.State.Statuses()[0].Queued)
+
+	close(tg.release)
+	assert.Eventually(t, func() bool { return len(tg.executed()) == 1 }, 5*time.Second, 5*time.Millisecond)
+}
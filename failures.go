@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/metrics"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/outbox"
+)
+
+// FailurePolicy tells what happens to a watcher once the target failed for it as many times in a row as its budget
+type FailurePolicy string
+
+const (
+	// Retry keeps retrying the change with backoff, the budget notwithstanding
+	Retry FailurePolicy = "retry"
+	// Skip moves the change to the dead letters and moves on to the next one
+	Skip FailurePolicy = "skip"
+	// Halt pauses the watcher until it's resumed through the admin API, the other watchers carry on
+	Halt FailurePolicy = "halt"
+)
+
+func ParseFailurePolicy(policy string) (FailurePolicy, error) {
+	switch FailurePolicy(policy) {
+	case Retry, Skip, Halt:
+		return FailurePolicy(policy), nil
+	}
+	return "", fmt.Errorf("unknown target failure policy '%s' found. Currently, supported policies are 'retry', 'skip', 'halt'", policy)
+}
+
+// targetFailed counts the failure of the target for the pending change against the budget of its path, and applies
+// the failure policy once the budget is exhausted. It tells whether the change is done with, that is, skipped.
+func (p *Poller) targetFailed(logger *log.Entry, entry outbox.Entry, err error) bool {
+	path := entry.Path
+	p.targetFailures[path]++
+	failures := p.targetFailures[path]
+	metrics.ConsecutiveTargetFailures.WithLabelValues(path).Set(float64(failures))
+	p.State.setTargetFailures(path, failures)
+	logger = logger.WithField("target_failures", failures)
+	if p.TargetFailurePolicy == Retry || failures < p.TargetFailureBudget {
+		logger.WithField("retry_in", p.retryLater(logger, entry).String()).Warn(err.Error())
+		return false
+	}
+
+	// a skipped change, or a resumed watcher, starts over with the whole budget
+	p.resetTargetFailures(path)
+	if p.TargetFailurePolicy == Skip {
+		logger.WithError(err).Error("target failure budget exhausted, moving the change to the dead letters")
+		p.bury(logger, entry)
+		return true
+	}
+	logger.WithError(err).Error("target failure budget exhausted, halting the watcher until it's resumed")
+	// due as soon as the watcher is resumed
+	entry.NextAttemptAt = time.Time{}
+	p.update(logger, entry)
+	p.State.halt(path)
+	metrics.WatchersHalted.WithLabelValues(path).Set(1)
+	return false
+}
+
+func (p *Poller) resetTargetFailures(path string) {
+	delete(p.targetFailures, path)
+	metrics.ConsecutiveTargetFailures.WithLabelValues(path).Set(0)
+	p.State.setTargetFailures(path, 0)
+}
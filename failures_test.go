@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+func TestParseFailurePolicy(t *testing.T) {
+	for _, policy := range []string{"retry", "skip", "halt"} {
+		parsed, err := ParseFailurePolicy(policy)
+		assert.NoError(t, err)
+		assert.Equal(t, FailurePolicy(policy), parsed)
+	}
+	_, err := ParseFailurePolicy("exit")
+	assert.EqualError(t, err, "unknown target failure policy 'exit' found. Currently, supported policies are 'retry', 'skip', 'halt'")
+}
+
+func TestVaultFailureLimit(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	poller := NewPoller(client, &mockTarget{}, "mock", time.Hour, []string{"missing"}, 1, 0)
+	done := make(chan error)
+	go func() { done <- poller.Run(make(chan os.Signal)) }()
+
+	assert.NoError(t, poller.Repoll(context.Background()))
+	assert.Equal(t, 1, int(poller.State.consecutiveFailures))
+	// the repolls are forced, hence, they read the path despite backing off
+	assert.Error(t, poller.Repoll(context.Background()))
+	assert.Error(t, <-done)
+}
+
+func TestTargetFailurePolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy FailurePolicy
+		// executions of the target expected after every poll
+		executions []int
+		halted     bool
+	}{
+		{policy: Retry, executions: []int{1, 2, 3, 4, 5}},
+		// the change gets skipped after 3 failures, the next one is delivered
+		{policy: Skip, executions: []int{1, 2, 3, 3, 4}},
+		{policy: Halt, executions: []int{1, 2, 3, 3, 3}, halted: true},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			mv := &mockVault{}
+			client := mv.setup(t)
+			defer mv.teardown()
+			mv.put("app", map[string]interface{}{"password": "foo"})
+			tg := &mockTarget{}
+			tg.setErr(errors.New("target is down"))
+			poller, sv, stop := runPoller(t, client, tg, "app")
+			defer stop()
+			poller.TargetFailurePolicy = tc.policy
+
+			for i, executions := range tc.executions {
+				if i == 4 {
+					mv.put("app", map[string]interface{}{"password": "bar"})
+				}
+				assert.NoError(t, poller.Repoll(context.Background()))
+				assert.Len(t, tg.executed(), executions, "poll %d", i+1)
+			}
+			status := poller.State.Statuses()[0]
+			assert.Equal(t, tc.halted, status.Halted)
+			assert.Equal(t, tc.halted, status.Paused)
+			if tc.policy == Skip {
+				events := tg.executed()
+				assert.Equal(t, target.KeyStore{"password": "foo"}, events[2].NewKeyStore)
+				assert.Equal(t, target.KeyStore{"password": "foo"}, events[3].OldKeyStore)
+				assert.Equal(t, target.KeyStore{"password": "bar"}, events[3].NewKeyStore)
+			}
+			if !tc.halted {
+				return
+			}
+
+			// resuming the halted watcher retries its change with the whole budget again, before delivering the newer one
+			tg.setErr(nil)
+			assert.Equal(t, http.StatusOK, adminRequest(t, http.MethodPost, sv.URL+"/v1/resume?path=app", nil))
+			assert.Eventually(t, func() bool { return len(tg.executed()) == 4 }, 5*time.Second, 5*time.Millisecond)
+			events := tg.executed()
+			assert.Equal(t, events[0].ID, events[3].ID)
+			assert.Equal(t, 4, events[3].Attempt)
+			// the newer change made while it was halted is picked up on the next read
+			assert.NoError(t, poller.Repoll(context.Background()))
+			events = tg.executed()
+			if assert.Len(t, events, 5) {
+				assert.Equal(t, target.KeyStore{"password": "foo"}, events[4].OldKeyStore)
+				assert.Equal(t, target.KeyStore{"password": "bar"}, events[4].NewKeyStore)
+			}
+			status = poller.State.Statuses()[0]
+			assert.False(t, status.Halted)
+			assert.Equal(t, 0, status.TargetFailures)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package dotenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// values made up of these characters only mean the same to shells and every dotenv parser without any quoting
+var safeValue = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]+$`)
+
+type Options struct {
+	// Export prefixes every line with 'export ' so that the file can be sourced by a shell
+	Export bool
+	// SanitizeKeys turns every key into a conventional environment variable name, see SanitizeKey
+	SanitizeKeys bool
+	// Prefix is prepended to every key, after sanitizing it
+	Prefix string
+}
+
+// Encode renders the key store as a dotenv file, one 'KEY=value' line per key, sorted by key so that
+// rendering the same key store always produces the same file.
+func Encode(keyStore map[string]interface{}, opts Options) (string, error) {
+	lines := map[string]string{}
+	originalKeys := map[string]string{}
+	for key, value := range keyStore {
+		name := key
+		if opts.SanitizeKeys {
+			name = SanitizeKey(key)
+		}
+		name = opts.Prefix + name
+		if original, ok := originalKeys[name]; ok {
+			return "", fmt.Errorf("keys '%s' and '%s' both end up as the variable '%s'", original, key, name)
+		}
+		originalKeys[name] = key
+
+		formatted, err := FormatValue(value)
+		if err != nil {
+			return "", fmt.Errorf("error occurred while formatting the value of the key '%s': %w", key, err)
+		}
+		line := name + "=" + Quote(formatted)
+		if opts.Export {
+			line = "export " + line
+		}
+		lines[name] = line
+	}
+
+	names := make([]string, 0, len(lines))
+	for name := range lines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rendered := make([]string, 0, len(names))
+	for _, name := range names {
+		rendered = append(rendered, lines[name])
+	}
+	return strings.Join(rendered, "\n"), nil
+}
+
+// SanitizeKey uppercases the key and replaces every character not allowed in an environment variable name,
+// like '.' or '-', with '_'. For example, 'db.password' becomes 'DB_PASSWORD'.
+func SanitizeKey(key string) string {
+	sanitized := []rune(strings.ToUpper(key))
+	for i, r := range sanitized {
+		if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && r != '_' {
+			sanitized[i] = '_'
+		}
+	}
+	if len(sanitized) == 0 || (sanitized[0] >= '0' && sanitized[0] <= '9') {
+		return "_" + string(sanitized)
+	}
+	return string(sanitized)
+}
+
+// FormatValue renders scalars as is and nested values, objects or lists, as JSON
+func FormatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool, float64, float32, int, int64, int32:
+		return fmt.Sprint(v), nil
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(encoded), nil
+	}
+}
+
+// Quote leaves safe values as they are, single-quotes the ones which can be taken literally and double-quotes
+// the rest (values containing single quotes or newlines), escaping whatever a shell or a dotenv parser would interpret.
+func Quote(value string) string {
+	if safeValue.MatchString(value) {
+		return value
+	}
+	if !strings.ContainsAny(value, "'\n\r") {
+		return "'" + value + "'"
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`", "\n", `\n`, "\r", `\r`)
+	return `"` + replacer.Replace(value) + `"`
+}
@@ -0,0 +1,65 @@
+package dotenv
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncode(t *testing.T) {
+	keyStore := map[string]interface{}{
+		"plain":     "bar",
+		"url":       "postgres://user@db:5432/app?sslmode=disable",
+		"spaces":    "hello world",
+		"comment":   "value #not-a-comment",
+		"quote":     "it's",
+		"dollar":    "pa$$word",
+		"multiline": "line1\nline2",
+		"number":    json.Number("42"),
+		"flag":      true,
+		"empty":     "",
+		"missing":   nil,
+		"nested":    map[string]interface{}{"a": "b"},
+		"list":      []interface{}{"x", 1.0},
+	}
+
+	content, err := Encode(keyStore, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, `comment='value #not-a-comment'
+dollar='pa$$word'
+empty=''
+flag=true
+list='["x",1]'
+missing=''
+multiline="line1\nline2"
+nested='{"a":"b"}'
+number=42
+plain=bar
+quote="it's"
+spaces='hello world'
+url='postgres://user@db:5432/app?sslmode=disable'`, content)
+}
+
+func TestEncodeWithExportAndSanitizedKeys(t *testing.T) {
+	keyStore := map[string]interface{}{
+		"db.password": "s3cr3t",
+		"api-key":     "abc",
+		"2fa_seed":    "xyz",
+	}
+
+	content, err := Encode(keyStore, Options{Export: true, SanitizeKeys: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "export API_KEY=abc\nexport DB_PASSWORD=s3cr3t\nexport _2FA_SEED=xyz", content)
+}
+
+func TestEncodeFailureWithCollidingKeys(t *testing.T) {
+	_, err := Encode(map[string]interface{}{"db.password": "a", "DB_PASSWORD": "b"}, Options{SanitizeKeys: true})
+	assert.Error(t, err)
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, "simple", Quote("simple"))
+	assert.Equal(t, "'two words'", Quote("two words"))
+	assert.Equal(t, "\"say \\\"hi\\\" it's \\$HOME and \\`cmd\\`\"", Quote("say \"hi\" it's $HOME and `cmd`"))
+}
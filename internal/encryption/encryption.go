@@ -0,0 +1,283 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	vault "github.com/hashicorp/vault/api"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+type Scheme string
+
+const (
+	None    Scheme = "none"
+	NaCl    Scheme = "nacl"
+	Transit Scheme = "transit"
+)
+
+const envelopeVersion = 1
+
+// Envelope is what an encrypted file holds, JSON encoded.
+//
+// With the 'nacl' scheme, the plaintext is encrypted with a random key using NaCl secretbox and that key is sealed,
+// with NaCl anonymous sealed boxes, to every recipient's public key. With the 'transit' scheme, the plaintext
+// is encrypted by the transit secrets engine of vault and only the vault ciphertext, like 'vault:v1:...', is kept.
+type Envelope struct {
+	Version      int         `json:"version"`
+	Scheme       Scheme      `json:"scheme"`
+	Recipients   []Recipient `json:"recipients,omitempty"`
+	Nonce        string      `json:"nonce,omitempty"`
+	TransitMount string      `json:"transit_mount,omitempty"`
+	TransitKey   string      `json:"transit_key,omitempty"`
+	Ciphertext   string      `json:"ciphertext"`
+}
+
+type Recipient struct {
+	PublicKey string `json:"public_key"`
+	SealedKey string `json:"sealed_key"`
+}
+
+// Settings configure how a target encrypts the files it writes
+type Settings struct {
+	Scheme       string
+	Recipients   string
+	TransitMount string
+	TransitKey   string
+
+	vaultClient *vault.Client
+}
+
+// Args registers the encryption flags under the given prefix, like '-<prefix>-encryption'
+func (s *Settings) Args(prefix, file string) {
+	flag.StringVar(&s.Scheme, prefix+"-encryption", string(None), fmt.Sprintf("Encryption of the %s. Currently, supported schemes are 'none', 'nacl' (NaCl sealed to the recipients' public keys) and 'transit' (vault's transit secrets engine). Use the 'decrypt' subcommand to read it", file))
+	flag.StringVar(&s.Recipients, prefix+"-encryption-recipients", "", fmt.Sprintf("'nacl' scheme only. Comma separated base64 encoded public keys, as generated by the 'keygen' subcommand, able to decrypt the %s", file))
+	flag.StringVar(&s.TransitMount, prefix+"-encryption-transit-mount", "transit", "'transit' scheme only. Path at which vault's transit secrets engine is mounted")
+	flag.StringVar(&s.TransitKey, prefix+"-encryption-transit-key", "", fmt.Sprintf("'transit' scheme only. Name of the transit key encrypting the %s", file))
+}
+
+func (s *Settings) SetVaultClient(client *vault.Client) {
+	s.vaultClient = client
+}
+
+func (s Settings) Enabled() bool {
+	return s.Scheme != "" && Scheme(s.Scheme) != None
+}
+
+// Encrypt returns the plaintext as is in case encryption isn't enabled, otherwise the JSON encoded Envelope
+func (s Settings) Encrypt(plaintext []byte) ([]byte, error) {
+	var envelope Envelope
+	var err error
+	switch Scheme(s.Scheme) {
+	case None, "":
+		return plaintext, nil
+	case NaCl:
+		envelope, err = encryptWithNaCl(plaintext, s.Recipients)
+	case Transit:
+		envelope, err = encryptWithTransit(s.vaultClient, s.TransitMount, s.TransitKey, plaintext)
+	default:
+		return nil, fmt.Errorf("unknown encryption scheme '%s' found. Currently, supported schemes are 'none', 'nacl', 'transit'", s.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while marshalling the encrypted envelope into JSON format: %w", err)
+	}
+	return encoded, nil
+}
+
+// Decrypt opens the JSON encoded Envelope, with the private key for the 'nacl' scheme or the vault client for the 'transit' one
+func Decrypt(encrypted []byte, privateKey *[32]byte, client *vault.Client) ([]byte, error) {
+	envelope := Envelope{}
+	if err := json.Unmarshal(encrypted, &envelope); err != nil {
+		return nil, fmt.Errorf("error occurred while parsing the encrypted envelope: %w", err)
+	}
+	if envelope.Version != envelopeVersion {
+		return nil, fmt.Errorf("unsupported encrypted envelope version '%d' found", envelope.Version)
+	}
+	switch envelope.Scheme {
+	case NaCl:
+		if privateKey == nil {
+			return nil, fmt.Errorf("a private key is required to decrypt the 'nacl' scheme")
+		}
+		return decryptWithNaCl(envelope, privateKey)
+	case Transit:
+		if client == nil {
+			return nil, fmt.Errorf("a vault client is required to decrypt the 'transit' scheme")
+		}
+		return decryptWithTransit(client, envelope)
+	default:
+		return nil, fmt.Errorf("unknown encryption scheme '%s' found in the envelope", envelope.Scheme)
+	}
+}
+
+// GenerateKey returns a new base64 encoded key pair for the 'nacl' scheme
+func GenerateKey() (string, string, error) {
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("error occurred while generating a key pair: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(publicKey[:]), base64.StdEncoding.EncodeToString(privateKey[:]), nil
+}
+
+// ParseKey parses a base64 encoded public or private key
+func ParseKey(encoded string) (*[32]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while decoding the key '%s': %w", encoded, err)
+	}
+	if len(decoded) != 32 {
+		return nil, fmt.Errorf("key '%s' is expected to be 32 bytes long, found %d bytes", encoded, len(decoded))
+	}
+	key := [32]byte{}
+	copy(key[:], decoded)
+	return &key, nil
+}
+
+func encryptWithNaCl(plaintext []byte, recipients string) (Envelope, error) {
+	publicKeys := []*[32]byte{}
+	for _, recipient := range strings.Split(recipients, ",") {
+		if recipient = strings.TrimSpace(recipient); recipient == "" {
+			continue
+		}
+		publicKey, err := ParseKey(recipient)
+		if err != nil {
+			return Envelope{}, err
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+	if len(publicKeys) == 0 {
+		return Envelope{}, fmt.Errorf("no recipients found to be provided for the 'nacl' encryption scheme")
+	}
+
+	var key [32]byte
+	var nonce [24]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return Envelope{}, fmt.Errorf("error occurred while generating the encryption key: %w", err)
+	}
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return Envelope{}, fmt.Errorf("error occurred while generating the nonce: %w", err)
+	}
+	envelope := Envelope{
+		Version:    envelopeVersion,
+		Scheme:     NaCl,
+		Nonce:      base64.StdEncoding.EncodeToString(nonce[:]),
+		Ciphertext: base64.StdEncoding.EncodeToString(secretbox.Seal(nil, plaintext, &nonce, &key)),
+	}
+	for _, publicKey := range publicKeys {
+		sealedKey, err := box.SealAnonymous(nil, key[:], publicKey, rand.Reader)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("error occurred while sealing the encryption key: %w", err)
+		}
+		envelope.Recipients = append(envelope.Recipients, Recipient{
+			PublicKey: base64.StdEncoding.EncodeToString(publicKey[:]),
+			SealedKey: base64.StdEncoding.EncodeToString(sealedKey),
+		})
+	}
+	return envelope, nil
+}
+
+func decryptWithNaCl(envelope Envelope, privateKey *[32]byte) ([]byte, error) {
+	derived, err := curve25519.X25519(privateKey[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while deriving the public key out of the private key: %w", err)
+	}
+	publicKey := [32]byte{}
+	copy(publicKey[:], derived)
+	encodedPublicKey := base64.StdEncoding.EncodeToString(publicKey[:])
+
+	for _, recipient := range envelope.Recipients {
+		if recipient.PublicKey != encodedPublicKey {
+			continue
+		}
+		sealedKey, err := base64.StdEncoding.DecodeString(recipient.SealedKey)
+		if err != nil {
+			return nil, fmt.Errorf("error occurred while decoding the sealed key: %w", err)
+		}
+		key, ok := box.OpenAnonymous(nil, sealedKey, &publicKey, privateKey)
+		if !ok || len(key) != 32 {
+			return nil, fmt.Errorf("failed to open the sealed key with the provided private key")
+		}
+		nonce, err := base64.StdEncoding.DecodeString(envelope.Nonce)
+		if err != nil || len(nonce) != 24 {
+			return nil, fmt.Errorf("invalid nonce found in the envelope")
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(envelope.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("error occurred while decoding the ciphertext: %w", err)
+		}
+		var secretKey [32]byte
+		var secretNonce [24]byte
+		copy(secretKey[:], key)
+		copy(secretNonce[:], nonce)
+		plaintext, ok := secretbox.Open(nil, ciphertext, &secretNonce, &secretKey)
+		if !ok {
+			return nil, fmt.Errorf("failed to decrypt the ciphertext, it might have been tampered with")
+		}
+		return plaintext, nil
+	}
+	return nil, fmt.Errorf("the provided private key isn't among the recipients of the envelope")
+}
+
+func encryptWithTransit(client *vault.Client, mount, key string, plaintext []byte) (Envelope, error) {
+	if client == nil {
+		return Envelope{}, fmt.Errorf("no vault client found to encrypt with the 'transit' scheme")
+	}
+	if key == "" {
+		return Envelope{}, fmt.Errorf("no transit key found to be provided for the 'transit' encryption scheme")
+	}
+	if mount == "" {
+		mount = "transit"
+	}
+	secret, err := client.Logical().Write(fmt.Sprintf("%s/encrypt/%s", mount, key), map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+	})
+	if err != nil {
+		return Envelope{}, fmt.Errorf("error occurred while encrypting with the transit key '%s': %w", key, err)
+	}
+	ciphertext, ok := transitField(secret, "ciphertext")
+	if !ok {
+		return Envelope{}, fmt.Errorf("no ciphertext found in the response of vault's transit secrets engine")
+	}
+	return Envelope{
+		Version:      envelopeVersion,
+		Scheme:       Transit,
+		TransitMount: mount,
+		TransitKey:   key,
+		Ciphertext:   ciphertext,
+	}, nil
+}
+
+func decryptWithTransit(client *vault.Client, envelope Envelope) ([]byte, error) {
+	secret, err := client.Logical().Write(fmt.Sprintf("%s/decrypt/%s", envelope.TransitMount, envelope.TransitKey), map[string]interface{}{
+		"ciphertext": envelope.Ciphertext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while decrypting with the transit key '%s': %w", envelope.TransitKey, err)
+	}
+	encoded, ok := transitField(secret, "plaintext")
+	if !ok {
+		return nil, fmt.Errorf("no plaintext found in the response of vault's transit secrets engine")
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while decoding the plaintext returned by vault's transit secrets engine: %w", err)
+	}
+	return plaintext, nil
+}
+
+func transitField(secret *vault.Secret, field string) (string, bool) {
+	if secret == nil || secret.Data == nil {
+		return "", false
+	}
+	value, ok := secret.Data[field].(string)
+	return value, ok
+}
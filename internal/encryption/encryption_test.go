@@ -0,0 +1,132 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockTransit struct {
+	server *httptest.Server
+}
+
+// setup serves a stand-in of vault's transit secrets engine which "encrypts" by reversing the base64 plaintext
+func (m *mockTransit) setup() (*vault.Client, error) {
+	m.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data := map[string]string{}
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/app":
+			data["ciphertext"] = "vault:v1:" + reverse(body["plaintext"])
+		case "/v1/transit/decrypt/app":
+			data["plaintext"] = reverse(strings.TrimPrefix(body["ciphertext"], "vault:v1:"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	config := vault.DefaultConfig()
+	config.Address = m.server.URL
+	client, err := vault.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken("token")
+	return client, nil
+}
+
+func (m *mockTransit) teardown() {
+	m.server.Close()
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func TestNaClRoundTrip(t *testing.T) {
+	alicePublic, alicePrivate, err := GenerateKey()
+	assert.NoError(t, err)
+	bobPublic, bobPrivate, err := GenerateKey()
+	assert.NoError(t, err)
+	_, evePrivate, err := GenerateKey()
+	assert.NoError(t, err)
+
+	s := Settings{Scheme: "nacl", Recipients: alicePublic + ", " + bobPublic}
+	encrypted, err := s.Encrypt([]byte("DB_PASSWORD=s3cr3t"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(encrypted), "s3cr3t")
+
+	for _, private := range []string{alicePrivate, bobPrivate} {
+		key, err := ParseKey(private)
+		assert.NoError(t, err)
+		plaintext, err := Decrypt(encrypted, key, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "DB_PASSWORD=s3cr3t", string(plaintext))
+	}
+
+	key, err := ParseKey(evePrivate)
+	assert.NoError(t, err)
+	_, err = Decrypt(encrypted, key, nil)
+	assert.EqualError(t, err, "the provided private key isn't among the recipients of the envelope")
+
+	envelope := Envelope{}
+	assert.NoError(t, json.Unmarshal(encrypted, &envelope))
+	ciphertext, err := base64.StdEncoding.DecodeString(envelope.Ciphertext)
+	assert.NoError(t, err)
+	ciphertext[0] ^= 0xff
+	envelope.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext)
+	tampered, err := json.Marshal(envelope)
+	assert.NoError(t, err)
+	key, err = ParseKey(alicePrivate)
+	assert.NoError(t, err)
+	_, err = Decrypt(tampered, key, nil)
+	assert.EqualError(t, err, "failed to decrypt the ciphertext, it might have been tampered with")
+}
+
+func TestTransitRoundTrip(t *testing.T) {
+	m := mockTransit{}
+	client, err := m.setup()
+	assert.NoError(t, err)
+	defer m.teardown()
+
+	s := Settings{Scheme: "transit", TransitMount: "transit", TransitKey: "app"}
+	s.SetVaultClient(client)
+	encrypted, err := s.Encrypt([]byte("s3cr3t"))
+	assert.NoError(t, err)
+
+	envelope := Envelope{}
+	assert.NoError(t, json.Unmarshal(encrypted, &envelope))
+	assert.Equal(t, Transit, envelope.Scheme)
+	assert.True(t, strings.HasPrefix(envelope.Ciphertext, "vault:v1:"))
+
+	plaintext, err := Decrypt(encrypted, nil, client)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cr3t", string(plaintext))
+}
+
+func TestEncryptWithoutScheme(t *testing.T) {
+	plaintext, err := Settings{}.Encrypt([]byte("as is"))
+	assert.NoError(t, err)
+	assert.Equal(t, "as is", string(plaintext))
+
+	_, err = Settings{Scheme: "nacl"}.Encrypt([]byte("x"))
+	assert.EqualError(t, err, "no recipients found to be provided for the 'nacl' encryption scheme")
+
+	_, err = Settings{Scheme: "rot13"}.Encrypt([]byte("x"))
+	assert.EqualError(t, err, "unknown encryption scheme 'rot13' found. Currently, supported schemes are 'none', 'nacl', 'transit'")
+}
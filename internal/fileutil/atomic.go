@@ -0,0 +1,107 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type WriteOptions struct {
+	Perm os.FileMode
+	// Uid and Gid of the written file, -1 leaves them to whatever the process creates files with
+	Uid int
+	Gid int
+	// CreateDirs creates the missing parent directories of the file
+	CreateDirs bool
+	// FollowSymlink writes to the file the path links to, rather than refusing to, if the file itself is a symlink.
+	// Like O_NOFOLLOW, only the last component of the path is checked, the symlinked directories leading to the
+	// file are followed regardless.
+	FollowSymlink bool
+}
+
+func DefaultWriteOptions(perm os.FileMode) WriteOptions {
+	return WriteOptions{Perm: perm, Uid: -1, Gid: -1}
+}
+
+// WriteAtomic writes the data to a temporary file next to path and renames it over path once it's synced to the disk,
+// so that a reader of path either sees the old or the new contents, never a half-written file.
+func WriteAtomic(path string, data []byte, opts WriteOptions) error {
+	path, err := resolvePath(path, opts.FollowSymlink)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if opts.CreateDirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error occurred while creating the directory '%s': %w", dir, err)
+		}
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error occurred while creating a temporary file under '%s': %w", dir, err)
+	}
+	tmpPath := tmp.Name()
+	// no-op once the rename succeeded
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error occurred while writing to the temporary file '%s': %w", tmpPath, err)
+	}
+	// the temporary file is created with 0600, hence, the contents are never readable by others before this
+	if err := tmp.Chmod(opts.Perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error occurred while setting the permissions of the temporary file '%s': %w", tmpPath, err)
+	}
+	if opts.Uid >= 0 || opts.Gid >= 0 {
+		if err := tmp.Chown(opts.Uid, opts.Gid); err != nil {
+			tmp.Close()
+			return fmt.Errorf("error occurred while setting the owner of the temporary file '%s': %w", tmpPath, err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error occurred while syncing the temporary file '%s': %w", tmpPath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error occurred while closing the temporary file '%s': %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("error occurred while renaming the temporary file '%s' to '%s': %w", tmpPath, path, err)
+	}
+	return syncDir(dir)
+}
+
+// resolvePath returns the path to actually write to, refusing to go through the file being a symlink unless told to
+func resolvePath(path string, followSymlink bool) (string, error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return path, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error occurred while inspecting the path '%s': %w", path, err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return path, nil
+	}
+	if !followSymlink {
+		return "", fmt.Errorf("refusing to write to '%s' as it is a symlink", path)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("error occurred while resolving the symlink '%s': %w", path, err)
+	}
+	return resolved, nil
+}
+
+// syncDir persists the rename itself, which lives in the directory's entries
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	defer d.Close()
+	// not every platform/filesystem supports syncing directories, and the rename already happened anyway
+	d.Sync()
+	return nil
+}
@@ -0,0 +1,68 @@
+package fileutil
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filePath := path.Join(dir, "secret.env")
+
+	assert.NoError(t, WriteAtomic(filePath, []byte("foo=bar"), DefaultWriteOptions(0600)))
+	assert.NoError(t, WriteAtomic(filePath, []byte("foo=baz"), DefaultWriteOptions(0640)))
+
+	contents, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo=baz", string(contents))
+
+	info, err := os.Stat(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files are expected to be cleaned up")
+}
+
+func TestWriteAtomicWithParentDirsAndOwner(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "nested", "dir", "secret.env")
+
+	assert.Error(t, WriteAtomic(filePath, []byte("foo=bar"), DefaultWriteOptions(0600)))
+
+	opts := WriteOptions{Perm: 0600, Uid: os.Getuid(), Gid: os.Getgid(), CreateDirs: true}
+	assert.NoError(t, WriteAtomic(filePath, []byte("foo=bar"), opts))
+	assert.FileExists(t, filePath)
+}
+
+func TestWriteAtomicWithSymlink(t *testing.T) {
+	dir := t.TempDir()
+	realPath := path.Join(dir, "real.env")
+	linkPath := path.Join(dir, "link.env")
+	assert.NoError(t, os.WriteFile(realPath, []byte("foo=bar"), 0600))
+	assert.NoError(t, os.Symlink(realPath, linkPath))
+
+	assert.EqualError(t, WriteAtomic(linkPath, []byte("foo=baz"), DefaultWriteOptions(0600)), "refusing to write to '"+linkPath+"' as it is a symlink")
+
+	opts := DefaultWriteOptions(0600)
+	opts.FollowSymlink = true
+	assert.NoError(t, WriteAtomic(linkPath, []byte("foo=baz"), opts))
+
+	info, err := os.Lstat(linkPath)
+	assert.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink, "the symlink itself is expected to be left intact")
+	contents, err := os.ReadFile(realPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo=baz", string(contents))
+
+	// only the file itself is checked, a symlinked directory leading to it is followed regardless
+	linkDir := path.Join(dir, "linked")
+	assert.NoError(t, os.Symlink(dir, linkDir))
+	assert.NoError(t, WriteAtomic(path.Join(linkDir, "real.env"), []byte("foo=qux"), DefaultWriteOptions(0600)))
+	contents, err = os.ReadFile(realPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo=qux", string(contents))
+}
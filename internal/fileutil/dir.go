@@ -0,0 +1,193 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	dataDirName    = "..data"
+	dataDirTmpName = "..data_tmp"
+	tsDirPattern   = "..2006_01_02_15_04_05."
+)
+
+// tsDirName matches the names os.MkdirTemp gives to the timestamped directories, that is, the pattern followed by random digits
+var tsDirName = regexp.MustCompile(`^\.\.\d{4}_\d{2}_\d{2}_\d{2}_\d{2}_\d{2}\.\d+$`)
+
+// WriteDirAtomic projects the files into the directory the same way kubelet projects secret volumes:
+// the files are written to a fresh timestamped directory, the '..data' symlink is flipped to it with a single rename,
+// and every file is exposed as '<dir>/<name>' -> '..data/<name>'. A reader of '<dir>/<name>' hence always sees
+// the files of a single consistent version, never a mix of the old and new ones.
+func WriteDirAtomic(dir string, files map[string][]byte, opts WriteOptions) error {
+	for name := range files {
+		if err := validateFileName(name); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error occurred while creating the directory '%s': %w", dir, err)
+	}
+	// nothing gets written if a file of the user is in the way
+	for name := range files {
+		if _, err := replaceableLink(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+
+	tsDir, err := os.MkdirTemp(dir, time.Now().UTC().Format(tsDirPattern))
+	if err != nil {
+		return fmt.Errorf("error occurred while creating a new timestamped directory under '%s': %w", dir, err)
+	}
+	if err := writeTsDir(tsDir, files, opts); err != nil {
+		os.RemoveAll(tsDir)
+		return err
+	}
+
+	tmpLink := filepath.Join(dir, dataDirTmpName)
+	os.Remove(tmpLink)
+	if err := os.Symlink(filepath.Base(tsDir), tmpLink); err != nil {
+		os.RemoveAll(tsDir)
+		return fmt.Errorf("error occurred while creating the symlink '%s': %w", tmpLink, err)
+	}
+	if err := os.Rename(tmpLink, filepath.Join(dir, dataDirName)); err != nil {
+		os.Remove(tmpLink)
+		os.RemoveAll(tsDir)
+		return fmt.Errorf("error occurred while flipping the '%s' symlink under '%s': %w", dataDirName, dir, err)
+	}
+
+	if err := linkUserVisibleFiles(dir, files); err != nil {
+		return err
+	}
+	if err := removeTsDirs(dir, filepath.Base(tsDir)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// removeTsDirs removes every timestamped directory but the current one: the previous one, as well as any left behind
+// by a write which didn't make it to flipping the '..data' symlink, like when crashing. Any other directory is left alone.
+func removeTsDirs(dir, current string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("error occurred while listing the directory '%s': %w", dir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !tsDirName.MatchString(name) || name == current {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("error occurred while removing the stale timestamped directory '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// replaceableLink tells whether there's a symlink of ours at the path, that is, one pointing into '..data'.
+// Anything else in the way, like a file of the user, is an error rather than getting replaced.
+func replaceableLink(link string) (bool, error) {
+	current, err := os.Readlink(link)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err == nil && strings.HasPrefix(current, dataDirName+string(filepath.Separator)) {
+		return true, nil
+	}
+	return false, fmt.Errorf("refusing to replace '%s' with a symlink into '%s' as it's no symlink of vaultie-talkie", link, dataDirName)
+}
+
+func validateFileName(name string) error {
+	if name == "" || name == "." || strings.HasPrefix(name, "..") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("'%s' can't be used as a file name", name)
+	}
+	return nil
+}
+
+func writeTsDir(tsDir string, files map[string][]byte, opts WriteOptions) error {
+	// os.MkdirTemp creates the directory with 0700 which would hide the files from a different owner
+	if err := os.Chmod(tsDir, 0755); err != nil {
+		return fmt.Errorf("error occurred while setting the permissions of the directory '%s': %w", tsDir, err)
+	}
+	if opts.Uid >= 0 || opts.Gid >= 0 {
+		if err := os.Chown(tsDir, opts.Uid, opts.Gid); err != nil {
+			return fmt.Errorf("error occurred while setting the owner of the directory '%s': %w", tsDir, err)
+		}
+	}
+	for name, data := range files {
+		path := filepath.Join(tsDir, name)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return fmt.Errorf("error occurred while creating the file '%s': %w", path, err)
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return fmt.Errorf("error occurred while writing to the file '%s': %w", path, err)
+		}
+		if err := f.Chmod(opts.Perm); err != nil {
+			f.Close()
+			return fmt.Errorf("error occurred while setting the permissions of the file '%s': %w", path, err)
+		}
+		if opts.Uid >= 0 || opts.Gid >= 0 {
+			if err := f.Chown(opts.Uid, opts.Gid); err != nil {
+				f.Close()
+				return fmt.Errorf("error occurred while setting the owner of the file '%s': %w", path, err)
+			}
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return fmt.Errorf("error occurred while syncing the file '%s': %w", path, err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("error occurred while closing the file '%s': %w", path, err)
+		}
+	}
+	return syncDir(tsDir)
+}
+
+// linkUserVisibleFiles makes sure that every file has its '<dir>/<name>' -> '..data/<name>' symlink
+// and removes the symlinks of the files which aren't projected anymore
+func linkUserVisibleFiles(dir string, files map[string][]byte) error {
+	for name := range files {
+		link := filepath.Join(dir, name)
+		linkTarget := filepath.Join(dataDirName, name)
+		if current, err := os.Readlink(link); err == nil && current == linkTarget {
+			continue
+		}
+		exists, err := replaceableLink(link)
+		if err != nil {
+			return err
+		}
+		if exists {
+			if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error occurred while replacing '%s' with a symlink: %w", link, err)
+			}
+		}
+		if err := os.Symlink(linkTarget, link); err != nil {
+			return fmt.Errorf("error occurred while creating the symlink '%s': %w", link, err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("error occurred while listing the directory '%s': %w", dir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, ok := files[name]; ok || strings.HasPrefix(name, "..") || entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		current, err := os.Readlink(filepath.Join(dir, name))
+		if err != nil || current != filepath.Join(dataDirName, name) {
+			// not one of ours
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("error occurred while removing the stale symlink '%s': %w", name, err)
+		}
+	}
+	return nil
+}
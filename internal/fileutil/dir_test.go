@@ -0,0 +1,100 @@
+package fileutil
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteDirAtomic(t *testing.T) {
+	dir := path.Join(t.TempDir(), "secrets")
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, os.WriteFile(path.Join(dir, "unrelated"), []byte("keep me"), 0644))
+
+	assert.NoError(t, WriteDirAtomic(dir, map[string][]byte{
+		"db_password": []byte("one"),
+		"db_user":     []byte("admin"),
+	}, DefaultWriteOptions(0640)))
+
+	contents, err := os.ReadFile(path.Join(dir, "db_password"))
+	assert.NoError(t, err)
+	assert.Equal(t, "one", string(contents))
+	link, err := os.Readlink(path.Join(dir, "db_password"))
+	assert.NoError(t, err)
+	assert.Equal(t, "..data/db_password", link)
+	info, err := os.Stat(path.Join(dir, "db_password"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	firstTsDir, err := os.Readlink(path.Join(dir, "..data"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(firstTsDir, ".."))
+
+	assert.NoError(t, WriteDirAtomic(dir, map[string][]byte{
+		"db_password": []byte("two"),
+	}, DefaultWriteOptions(0640)))
+
+	contents, err = os.ReadFile(path.Join(dir, "db_password"))
+	assert.NoError(t, err)
+	assert.Equal(t, "two", string(contents))
+	_, err = os.Lstat(path.Join(dir, "db_user"))
+	assert.True(t, os.IsNotExist(err), "symlinks of the keys which are gone are expected to be removed")
+	assert.NoDirExists(t, path.Join(dir, firstTsDir))
+	assert.FileExists(t, path.Join(dir, "unrelated"))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Len(t, names, 4, "expected only '..data', one timestamped directory, 'db_password' and 'unrelated', found %v", names)
+}
+
+func TestWriteDirAtomicFailureWithInvalidName(t *testing.T) {
+	dir := path.Join(t.TempDir(), "secrets")
+	for _, name := range []string{"../escape", "..data", "a/b", ""} {
+		assert.Error(t, WriteDirAtomic(dir, map[string][]byte{name: []byte("x")}, DefaultWriteOptions(0600)))
+	}
+}
+
+func TestWriteDirAtomicFailureWithFileInTheWay(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(dir, "db_password"), []byte("mine"), 0644))
+
+	err := WriteDirAtomic(dir, map[string][]byte{"db_password": []byte("one")}, DefaultWriteOptions(0640))
+	assert.ErrorContains(t, err, "refusing to replace")
+	contents, err := os.ReadFile(path.Join(dir, "db_password"))
+	assert.NoError(t, err)
+	assert.Equal(t, "mine", string(contents))
+	_, err = os.Lstat(path.Join(dir, dataDirName))
+	assert.True(t, os.IsNotExist(err))
+
+	// neither is a symlink pointing elsewhere
+	assert.NoError(t, os.Remove(path.Join(dir, "db_password")))
+	assert.NoError(t, os.Symlink("/etc/passwd", path.Join(dir, "db_password")))
+	err = WriteDirAtomic(dir, map[string][]byte{"db_password": []byte("one")}, DefaultWriteOptions(0640))
+	assert.ErrorContains(t, err, "refusing to replace")
+}
+
+func TestWriteDirAtomicRemovesOrphanedTsDirs(t *testing.T) {
+	dir := t.TempDir()
+	// left behind by a write crashing before flipping the '..data' symlink
+	orphan := path.Join(dir, "..2022_01_01_00_00_00.123")
+	assert.NoError(t, os.Mkdir(orphan, 0755))
+	assert.NoError(t, os.WriteFile(path.Join(orphan, "db_password"), []byte("old"), 0600))
+	// whereas the dot-dot directories of anybody else are left alone
+	foreign := path.Join(dir, "..keep")
+	assert.NoError(t, os.Mkdir(foreign, 0755))
+
+	assert.NoError(t, WriteDirAtomic(dir, map[string][]byte{"db_password": []byte("one")}, DefaultWriteOptions(0640)))
+	_, err := os.Stat(orphan)
+	assert.True(t, os.IsNotExist(err))
+	assert.DirExists(t, foreign)
+	contents, err := os.ReadFile(path.Join(dir, "db_password"))
+	assert.NoError(t, err)
+	assert.Equal(t, "one", string(contents))
+}
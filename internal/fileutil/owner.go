@@ -0,0 +1,40 @@
+package fileutil
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+// LookupIds resolves the user and group, each given either by name or by id, into their numeric ids.
+// An empty user or group resolves to -1.
+func LookupIds(owner, group string) (int, int, error) {
+	uid, gid := -1, -1
+	if owner != "" {
+		if id, err := strconv.Atoi(owner); err == nil {
+			uid = id
+		} else {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return -1, -1, fmt.Errorf("error occurred while looking up the user '%s': %w", owner, err)
+			}
+			if uid, err = strconv.Atoi(u.Uid); err != nil {
+				return -1, -1, fmt.Errorf("user '%s' doesn't have a numeric uid: %s", owner, u.Uid)
+			}
+		}
+	}
+	if group != "" {
+		if id, err := strconv.Atoi(group); err == nil {
+			gid = id
+		} else {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return -1, -1, fmt.Errorf("error occurred while looking up the group '%s': %w", group, err)
+			}
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return -1, -1, fmt.Errorf("group '%s' doesn't have a numeric gid: %s", group, g.Gid)
+			}
+		}
+	}
+	return uid, gid, nil
+}
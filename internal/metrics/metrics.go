@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "vaultie_talkie"
+
+type Outcome string
+
+const (
+	Success          Outcome = "success"
+	Failure          Outcome = "failure"
+	PermanentFailure Outcome = "permanent_failure"
+)
+
+var (
+	Polls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "polls_total",
+		Help:      "Reads of the watched vault paths.",
+	}, []string{"path"})
+	PollErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "poll_errors_total",
+		Help:      "Failed reads of the watched vault paths.",
+	}, []string{"path"})
+	PollDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "poll_duration_seconds",
+		Help:      "Duration of the reads of the watched vault paths.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"path"})
+	LastSuccessfulPoll = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_poll_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful read of the watched vault paths.",
+	}, []string{"path"})
+	ChangesDetected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "changes_detected_total",
+		Help:      "Changes detected at the watched vault paths.",
+	}, []string{"path"})
+	TargetExecutions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "target_executions_total",
+		Help:      "Executions of the target by their outcome: success, failure (retried) or permanent_failure (skipped).",
+	}, []string{"target", "outcome"})
+	TargetExecutionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "target_execution_duration_seconds",
+		Help:      "Duration of the executions of the target.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"target"})
+	DeliveryLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "delivery_latency_seconds",
+		Help:      "Duration between detecting a change and the target successfully executing for it, retries included.",
+		Buckets:   []float64{.1, .5, 1, 5, 10, 30, 60, 300, 900, 3600},
+	}, []string{"target"})
+	ConsecutiveFailures = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "consecutive_failures",
+		Help:      "Vault read failures in a row borne by the poller so far.",
+	})
+	ConsecutiveTargetFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "consecutive_target_failures",
+		Help:      "Failed executions of the target in a row for the watched vault paths.",
+	}, []string{"path"})
+	ChangesSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "changes_skipped_total",
+		Help:      "Changes at the watched vault paths which the target got skipped for after failing, and moved to the dead letters.",
+	}, []string{"path"})
+	ChangesCoalesced = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "changes_coalesced_total",
+		Help:      "Changes at the watched vault paths coalesced with earlier ones within the debounce window.",
+	}, []string{"path"})
+	QueuedChanges = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queued_changes",
+		Help:      "Changes at the watched vault paths pending for the target.",
+	}, []string{"path"})
+	WatchersHalted = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "watcher_halted",
+		Help:      "Whether the watcher of the vault path is halted after exhausting its target failure budget, 1 meaning halted.",
+	}, []string{"path"})
+	FailureLimit = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "failure_limit",
+		Help:      "Vault read failures in a row the poller bears before exiting, -1 meaning no limit.",
+	})
+	PollingInterval = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "polling_interval_seconds",
+		Help:      "Duration until the next read of the vault path, as adapted to its recent changes and failures, and jittered.",
+	}, []string{"path"})
+	TokenTTL = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "token_ttl_seconds",
+		Help:      "Remaining time to live of the vault access token, 0 meaning it never expires.",
+	})
+)
+
+// Registry holds every metric of vaultie-talkie along with the go runtime and process ones
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		Polls, PollErrors, PollDuration, LastSuccessfulPoll, ChangesDetected,
+		TargetExecutions, TargetExecutionDuration, DeliveryLatency,
+		ConsecutiveFailures, ConsecutiveTargetFailures, ChangesSkipped, ChangesCoalesced, QueuedChanges, WatchersHalted, FailureLimit, PollingInterval, TokenTTL,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
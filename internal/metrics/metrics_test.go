@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	Polls.WithLabelValues("app/config").Inc()
+	TargetExecutions.WithLabelValues("webhook", string(Success)).Inc()
+	FailureLimit.Set(3)
+
+	sv := httptest.NewServer(Handler())
+	defer sv.Close()
+	resp, err := http.Get(sv.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	assert.Contains(t, string(body), `vaultie_talkie_polls_total{path="app/config"} 1`)
+	assert.Contains(t, string(body), `vaultie_talkie_target_executions_total{outcome="success",target="webhook"} 1`)
+	assert.Contains(t, string(body), "vaultie_talkie_failure_limit 3")
+	assert.Contains(t, string(body), "go_goroutines")
+}
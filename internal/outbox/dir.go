@@ -0,0 +1,222 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/fileutil"
+)
+
+const (
+	pendingDir    = "pending"
+	deadDir       = "dead"
+	deliveredFile = "delivered.json"
+)
+
+// Dir is an outbox persisting every entry as a JSON file, named after its sequence number and ID, under the
+// 'pending' and 'dead' subdirectories, along with the sequence number of the latest entry delivered of every path
+// in 'delivered.json'. The entries hold the secrets, hence, they're only accessible by the owner.
+// The directory is the source of truth, so that the dead letters can be redriven while the poller is running.
+type Dir struct {
+	mu  sync.Mutex
+	dir string
+	seq uint64
+}
+
+func NewDir(dir string) (*Dir, error) {
+	for _, sub := range []string{pendingDir, deadDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, fmt.Errorf("error occurred while creating the outbox directory '%s': %w", filepath.Join(dir, sub), err)
+		}
+	}
+	d := &Dir{dir: dir}
+	// the sequence carries on from the latest entry, pending or dead
+	for _, sub := range []string{pendingDir, deadDir} {
+		names, err := d.list(sub)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if seq, _ := parseName(name); seq > d.seq {
+				d.seq = seq
+			}
+		}
+	}
+	return d, nil
+}
+
+func (d *Dir) Enqueue(entry *Entry) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.seq++
+	entry.Seq = d.seq
+	return d.write(pendingDir, *entry)
+}
+
+func (d *Dir) Pending() ([]Entry, error) {
+	return d.read(pendingDir)
+}
+
+func (d *Dir) Update(entry Entry) error {
+	return d.write(pendingDir, entry)
+}
+
+func (d *Dir) Delete(entry Entry) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delivered, err := d.delivered()
+	if err != nil {
+		return err
+	}
+	if entry.Seq > delivered[entry.Path] {
+		delivered[entry.Path] = entry.Seq
+		contents, err := json.Marshal(delivered)
+		if err != nil {
+			return fmt.Errorf("error occurred while marshalling the delivered entries: %w", err)
+		}
+		path := filepath.Join(d.dir, deliveredFile)
+		if err := fileutil.WriteAtomic(path, contents, fileutil.DefaultWriteOptions(0600)); err != nil {
+			return fmt.Errorf("error occurred while writing the delivered entries to '%s': %w", path, err)
+		}
+	}
+	return d.remove(pendingDir, entry)
+}
+
+// delivered returns the sequence number of the latest entry delivered, of every path
+func (d *Dir) delivered() (map[string]uint64, error) {
+	delivered := map[string]uint64{}
+	path := filepath.Join(d.dir, deliveredFile)
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return delivered, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while reading the delivered entries from '%s': %w", path, err)
+	}
+	if err := json.Unmarshal(contents, &delivered); err != nil {
+		return nil, fmt.Errorf("error occurred while parsing the delivered entries from '%s': %w", path, err)
+	}
+	return delivered, nil
+}
+
+func (d *Dir) Bury(entry Entry) error {
+	if err := d.write(deadDir, entry); err != nil {
+		return err
+	}
+	return d.remove(pendingDir, entry)
+}
+
+func (d *Dir) DeadLetters() ([]Entry, error) {
+	return d.read(deadDir)
+}
+
+func (d *Dir) Redrive(id string) (Entry, error) {
+	dead, err := d.read(deadDir)
+	if err != nil {
+		return Entry{}, err
+	}
+	d.mu.Lock()
+	delivered, err := d.delivered()
+	d.mu.Unlock()
+	if err != nil {
+		return Entry{}, err
+	}
+	for _, entry := range dead {
+		if entry.ID != id {
+			continue
+		}
+		if delivered[entry.Path] > entry.Seq {
+			return Entry{}, ErrSuperseded
+		}
+		if err := d.write(pendingDir, redriven(entry)); err != nil {
+			return Entry{}, err
+		}
+		return redriven(entry), d.remove(deadDir, entry)
+	}
+	return Entry{}, ErrNotFound
+}
+
+func fileName(entry Entry) string {
+	return fmt.Sprintf("%020d-%s.json", entry.Seq, entry.ID)
+}
+
+// parseName returns the sequence number of the entry file, and false for any other file, like a temporary one
+func parseName(name string) (uint64, bool) {
+	if strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(strings.SplitN(name, "-", 2)[0], 10, 64)
+	return seq, err == nil
+}
+
+func (d *Dir) list(sub string) ([]string, error) {
+	files, err := os.ReadDir(filepath.Join(d.dir, sub))
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while listing the outbox directory '%s': %w", filepath.Join(d.dir, sub), err)
+	}
+	names := []string{}
+	for _, file := range files {
+		if _, ok := parseName(file.Name()); ok && !file.IsDir() {
+			names = append(names, file.Name())
+		}
+	}
+	// zero-padded sequence numbers sort as strings
+	sort.Strings(names)
+	return names, nil
+}
+
+func (d *Dir) read(sub string) ([]Entry, error) {
+	names, err := d.list(sub)
+	if err != nil {
+		return nil, err
+	}
+	entries := []Entry{}
+	for _, name := range names {
+		path := filepath.Join(d.dir, sub, name)
+		contents, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			// delivered or redriven in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error occurred while reading the outbox entry '%s': %w", path, err)
+		}
+		entry := Entry{}
+		// the numbers of the key stores stay as precise as vault returned them
+		decoder := json.NewDecoder(bytes.NewReader(contents))
+		decoder.UseNumber()
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("error occurred while parsing the outbox entry '%s': %w", path, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (d *Dir) write(sub string, entry Entry) error {
+	contents, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error occurred while marshalling the outbox entry '%s': %w", entry.ID, err)
+	}
+	path := filepath.Join(d.dir, sub, fileName(entry))
+	if err := fileutil.WriteAtomic(path, contents, fileutil.DefaultWriteOptions(0600)); err != nil {
+		return fmt.Errorf("error occurred while writing the outbox entry '%s': %w", path, err)
+	}
+	return nil
+}
+
+func (d *Dir) remove(sub string, entry Entry) error {
+	path := filepath.Join(d.dir, sub, fileName(entry))
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error occurred while removing the outbox entry '%s': %w", path, err)
+	}
+	return nil
+}
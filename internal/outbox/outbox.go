@@ -0,0 +1,157 @@
+package outbox
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+// Entry is a change queued for the target to be executed for
+type Entry struct {
+	// Seq orders the entries, the target is executed for the entries of a path in this order
+	Seq         uint64          `json:"seq"`
+	ID          string          `json:"id"`
+	Path        string          `json:"path"`
+	Version     int             `json:"version"`
+	OldKeyStore target.KeyStore `json:"old_key_store"`
+	NewKeyStore target.KeyStore `json:"new_key_store"`
+	DetectedAt  time.Time       `json:"detected_at"`
+	// Attempts counts the executions of the target for the entry so far
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error,omitempty"`
+	// TraceParent is the W3C traceparent of the change, so that every execution of the target joins its trace
+	TraceParent string `json:"trace_parent,omitempty"`
+}
+
+// Outbox holds the pending entries, in the order they were enqueued, and the dead letters, that is,
+// the entries the target got skipped for
+type Outbox interface {
+	// Enqueue assigns the entry its sequence number and stores it as pending
+	Enqueue(entry *Entry) error
+	Pending() ([]Entry, error)
+	// Update stores the pending entry after an execution of the target failed for it
+	Update(entry Entry) error
+	// Delete removes the pending entry once the target got executed successfully for it
+	Delete(entry Entry) error
+	// Bury moves the pending entry to the dead letters
+	Bury(entry Entry) error
+	DeadLetters() ([]Entry, error)
+	// Redrive moves the dead letter back to the pending entries, with its attempts reset, unless a later entry
+	// of its path got delivered already, as it would roll the target back to stale key stores
+	Redrive(id string) (Entry, error)
+}
+
+var (
+	// ErrNotFound is returned for a dead letter which doesn't exist
+	ErrNotFound = errors.New("dead letter not found")
+	// ErrSuperseded is returned for a dead letter of a path which a later entry got delivered for
+	ErrSuperseded = errors.New("a later change of the path got delivered already")
+)
+
+func redriven(entry Entry) Entry {
+	entry.Attempts = 0
+	entry.NextAttemptAt = time.Time{}
+	return entry
+}
+
+// Memory is an outbox which doesn't outlive the process
+type Memory struct {
+	mu      sync.Mutex
+	seq     uint64
+	pending []Entry
+	dead    []Entry
+	// the sequence number of the latest entry delivered, of every path
+	delivered map[string]uint64
+}
+
+func NewMemory() *Memory {
+	return &Memory{delivered: map[string]uint64{}}
+}
+
+func (m *Memory) Enqueue(entry *Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	entry.Seq = m.seq
+	m.pending = append(m.pending, *entry)
+	return nil
+}
+
+func (m *Memory) Pending() ([]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Entry{}, m.pending...), nil
+}
+
+func (m *Memory) Update(entry Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.pending {
+		if m.pending[i].Seq == entry.Seq {
+			m.pending[i] = entry
+			return nil
+		}
+	}
+	return fmt.Errorf("pending entry '%s' not found", entry.ID)
+}
+
+func (m *Memory) Delete(entry Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending = remove(m.pending, entry.Seq)
+	if entry.Seq > m.delivered[entry.Path] {
+		m.delivered[entry.Path] = entry.Seq
+	}
+	return nil
+}
+
+func (m *Memory) Bury(entry Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending = remove(m.pending, entry.Seq)
+	m.dead = append(m.dead, entry)
+	return nil
+}
+
+func (m *Memory) DeadLetters() ([]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Entry{}, m.dead...), nil
+}
+
+func (m *Memory) Redrive(id string) (Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, entry := range m.dead {
+		if entry.ID == id {
+			if m.delivered[entry.Path] > entry.Seq {
+				return Entry{}, ErrSuperseded
+			}
+			m.dead = append(m.dead[:i], m.dead[i+1:]...)
+			entry = redriven(entry)
+			m.pending = append(m.pending, entry)
+			// the redriven entry keeps its place in the order
+			sortBySeq(m.pending)
+			return entry, nil
+		}
+	}
+	return Entry{}, ErrNotFound
+}
+
+func remove(entries []Entry, seq uint64) []Entry {
+	for i := range entries {
+		if entries[i].Seq == seq {
+			return append(entries[:i], entries[i+1:]...)
+		}
+	}
+	return entries
+}
+
+func sortBySeq(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+}
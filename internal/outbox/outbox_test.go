@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+func ids(entries []Entry) []string {
+	ids := []string{}
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+func TestOutbox(t *testing.T) {
+	for name, newOutbox := range map[string]func(t *testing.T) Outbox{
+		"memory": func(t *testing.T) Outbox { return NewMemory() },
+		"dir": func(t *testing.T) Outbox {
+			d, err := NewDir(t.TempDir())
+			assert.NoError(t, err)
+			return d
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			o := newOutbox(t)
+			for _, id := range []string{"a", "b", "c"} {
+				path := "app"
+				if id == "b" {
+					path = "other"
+				}
+				entry := &Entry{ID: id, Path: path, NewKeyStore: target.KeyStore{"password": json.Number("1")}, DetectedAt: time.Now()}
+				assert.NoError(t, o.Enqueue(entry))
+				assert.NotZero(t, entry.Seq)
+			}
+			pending, err := o.Pending()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"a", "b", "c"}, ids(pending))
+			assert.Equal(t, target.KeyStore{"password": json.Number("1")}, pending[0].NewKeyStore)
+
+			a := pending[0]
+			a.Attempts = 2
+			a.LastError = "target is down"
+			assert.NoError(t, o.Update(a))
+			assert.NoError(t, o.Bury(a))
+			assert.NoError(t, o.Delete(pending[1]))
+			pending, err = o.Pending()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"c"}, ids(pending))
+			dead, err := o.DeadLetters()
+			assert.NoError(t, err)
+			if assert.Equal(t, []string{"a"}, ids(dead)) {
+				assert.Equal(t, "target is down", dead[0].LastError)
+				assert.Equal(t, 2, dead[0].Attempts)
+			}
+
+			// the redriven entry goes back ahead of the later ones
+			redriven, err := o.Redrive("a")
+			assert.NoError(t, err)
+			assert.Equal(t, 0, redriven.Attempts)
+			pending, err = o.Pending()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"a", "c"}, ids(pending))
+			dead, err = o.DeadLetters()
+			assert.NoError(t, err)
+			assert.Empty(t, dead)
+
+			_, err = o.Redrive("a")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			// but not once a later entry of its path got delivered
+			assert.NoError(t, o.Bury(pending[0]))
+			assert.NoError(t, o.Delete(pending[1]))
+			_, err = o.Redrive("a")
+			assert.ErrorIs(t, err, ErrSuperseded)
+			dead, err = o.DeadLetters()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"a"}, ids(dead))
+		})
+	}
+}
+
+func TestDirPersists(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDir(dir)
+	assert.NoError(t, err)
+	first := &Entry{ID: "a", Path: "app"}
+	assert.NoError(t, d.Enqueue(first))
+	second := &Entry{ID: "b", Path: "app"}
+	assert.NoError(t, d.Enqueue(second))
+	assert.NoError(t, d.Bury(*second))
+
+	info, err := os.Stat(filepath.Join(dir, "pending", fileName(*first)))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	// temporary and unrelated files are ignored
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "pending", ".00000000000000000003-c.json.tmp-1"), nil, 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "pending", "README"), nil, 0600))
+
+	// the sequence carries on after the dead letters too
+	d, err = NewDir(dir)
+	assert.NoError(t, err)
+	third := &Entry{ID: "c", Path: "app"}
+	assert.NoError(t, d.Enqueue(third))
+	assert.Equal(t, uint64(3), third.Seq)
+	pending, err := d.Pending()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, ids(pending))
+}
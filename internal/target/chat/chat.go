@@ -0,0 +1,192 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+const DefaultMessageTemplate = "Secret at `{{ .Path }}` changed (version {{ .Version }}): {{ len .Added }} added, {{ len .Removed }} removed, {{ len .Changed }} changed"
+
+// TemplateData is what message templates of every chat target are executed against.
+type TemplateData struct {
+	Path    string
+	Version int
+	target.Diff
+}
+
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "Added"
+	Removed ChangeKind = "Removed"
+	Changed ChangeKind = "Changed"
+)
+
+type KeyChange struct {
+	Key      string
+	Kind     ChangeKind
+	OldValue interface{}
+	NewValue interface{}
+	Redacted bool
+}
+
+type Section struct {
+	Title   string
+	Changes []KeyChange
+}
+
+// Notification is the chat-platform agnostic content of a message about a key store change.
+// Every chat target renders it into the payload its platform understands.
+type Notification struct {
+	Headline string
+	Path     string
+	Version  int
+	Sections []Section
+}
+
+func Render(event target.Event, messageTemplate string, showValues bool) (Notification, error) {
+	data := TemplateData{
+		Path:    event.Path,
+		Version: event.Version,
+		Diff:    target.ComputeDiff(event.OldKeyStore, event.NewKeyStore),
+	}
+
+	if messageTemplate == "" {
+		messageTemplate = DefaultMessageTemplate
+	}
+	tmpl, err := template.New("message").Parse(messageTemplate)
+	if err != nil {
+		return Notification{}, fmt.Errorf("error occurred while parsing the message template: %w", err)
+	}
+	headline := new(bytes.Buffer)
+	if err := tmpl.Execute(headline, data); err != nil {
+		return Notification{}, fmt.Errorf("error occurred while executing the message template: %w", err)
+	}
+
+	notification := Notification{
+		Headline: headline.String(),
+		Path:     data.Path,
+		Version:  data.Version,
+	}
+	for _, kind := range []struct {
+		kind ChangeKind
+		keys []string
+	}{
+		{Added, data.Added},
+		{Removed, data.Removed},
+		{Changed, data.Changed},
+	} {
+		if len(kind.keys) == 0 {
+			continue
+		}
+		section := Section{Title: string(kind.kind)}
+		for _, key := range kind.keys {
+			change := KeyChange{Key: key, Kind: kind.kind, Redacted: !showValues}
+			if showValues {
+				change.OldValue = event.OldKeyStore[key]
+				change.NewValue = event.NewKeyStore[key]
+			}
+			section.Changes = append(section.Changes, change)
+		}
+		notification.Sections = append(notification.Sections, section)
+	}
+	return notification, nil
+}
+
+// Escaper escapes a key or a value so that the chat platform shows it as is
+type Escaper func(string) string
+
+var (
+	// EscapeMrkdwn escapes what Slack, and Mattermost for compatibility with it, turn into mentions and links,
+	// like a key named '<!channel>'
+	EscapeMrkdwn Escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+	// EscapeBackticks swaps the backticks for look-alike grave accents, as a backtick would close the inline code quoting
+	// the key or the value on Discord, which supports no escaping within it
+	EscapeBackticks Escaper = strings.NewReplacer("`", "ˋ").Replace
+	// Verbatim leaves the text as is, for the platforms which don't interpret it within the quotes
+	Verbatim Escaper = func(text string) string { return text }
+)
+
+// Format renders the change on a single line, wrapping the key and the values, escaped, within quote,
+// for example "`" for the platforms supporting inline code.
+func (c KeyChange) Format(quote string, escape Escaper) string {
+	key := quote + escape(c.Key) + quote
+	if c.Redacted {
+		return key
+	}
+	value := func(v interface{}) string {
+		return quote + escape(fmt.Sprint(v)) + quote
+	}
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("%s: %s", key, value(c.NewValue))
+	case Removed:
+		return fmt.Sprintf("%s: %s", key, value(c.OldValue))
+	default:
+		return fmt.Sprintf("%s: %s → %s", key, value(c.OldValue), value(c.NewValue))
+	}
+}
+
+// Truncate cuts the text down to limit runes, ending it with an ellipsis if it had to, for the platforms capping
+// the length of a message
+func Truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(append(runes[:limit-1], '…'))
+}
+
+// Markdown renders the notification as a single markdown text, which is what Discord and Mattermost expect.
+func (n Notification) Markdown(escape Escaper) string {
+	lines := []string{
+		n.Headline,
+		"",
+		fmt.Sprintf("**Path:** `%s`", n.Path),
+		fmt.Sprintf("**Version:** %d", n.Version),
+	}
+	for _, section := range n.Sections {
+		lines = append(lines, "", fmt.Sprintf("**%s**", section.Title))
+		for _, change := range section.Changes {
+			lines = append(lines, "- "+change.Format("`", escape))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Post delivers the JSON payload to the incoming-webhook URL of the chat platform.
+func Post(platform, url string, payload interface{}) error {
+	reqBody := new(bytes.Buffer)
+	if err := json.NewEncoder(reqBody).Encode(payload); err != nil {
+		return fmt.Errorf("error occurred while marshalling the JSON of the %s message: %w", platform, err)
+	}
+
+	httpClient := http.Client{
+		Timeout: 5 * time.Second,
+	}
+	log.Debugf("Posting the %s message to the incoming-webhook", platform)
+
+	resp, err := httpClient.Post(url, "application/json", reqBody)
+	if err != nil {
+		return fmt.Errorf("error occurred while making the request to the %s incoming-webhook: %w", platform, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("%s responded with the status code '%d': error occurred while reading the response body: %w", platform, resp.StatusCode, err)
+		}
+		return fmt.Errorf("%s responded with the status code '%d': %s", platform, resp.StatusCode, string(respBody))
+	}
+	log.Debugf("%s message delivered with the status code %d", platform, resp.StatusCode)
+	return nil
+}
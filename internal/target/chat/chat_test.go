@@ -0,0 +1,82 @@
+package chat
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+func sampleEvent() target.Event {
+	return target.Event{
+		Path:    "applications/ecommerce",
+		Version: 2,
+		OldKeyStore: target.KeyStore(map[string]interface{}{
+			"stale":   "old-secret",
+			"changed": "before-secret",
+		}),
+		NewKeyStore: target.KeyStore(map[string]interface{}{
+			"a":       "new-secret",
+			"changed": "after-secret",
+		}),
+	}
+}
+
+func TestRenderWithoutValues(t *testing.T) {
+	notification, err := Render(sampleEvent(), "", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "Secret at `applications/ecommerce` changed (version 2): 1 added, 1 removed, 1 changed", notification.Headline)
+	assert.Equal(t, "applications/ecommerce", notification.Path)
+	assert.Equal(t, 2, notification.Version)
+	assert.Equal(t, []Section{
+		{Title: "Added", Changes: []KeyChange{{Key: "a", Kind: Added, Redacted: true}}},
+		{Title: "Removed", Changes: []KeyChange{{Key: "stale", Kind: Removed, Redacted: true}}},
+		{Title: "Changed", Changes: []KeyChange{{Key: "changed", Kind: Changed, Redacted: true}}},
+	}, notification.Sections)
+
+	expectedMarkdown := "Secret at `applications/ecommerce` changed (version 2): 1 added, 1 removed, 1 changed\n\n" +
+		"**Path:** `applications/ecommerce`\n**Version:** 2\n\n" +
+		"**Added**\n- `a`\n\n**Removed**\n- `stale`\n\n**Changed**\n- `changed`"
+	assert.Equal(t, expectedMarkdown, notification.Markdown(Verbatim))
+}
+
+func TestRenderWithValues(t *testing.T) {
+	notification, err := Render(sampleEvent(), "{{ .Path }}: {{ .Added }}", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "applications/ecommerce: [a]", notification.Headline)
+	assert.Len(t, notification.Sections, 3)
+	assert.Equal(t, "`a`: `new-secret`", notification.Sections[0].Changes[0].Format("`", Verbatim))
+	assert.Equal(t, "'stale': 'old-secret'", notification.Sections[1].Changes[0].Format("'", Verbatim))
+	assert.Equal(t, "changed: before-secret → after-secret", notification.Sections[2].Changes[0].Format("", Verbatim))
+}
+
+func TestFormatEscaped(t *testing.T) {
+	change := KeyChange{Key: "<!channel>", Kind: Changed, OldValue: "a&b", NewValue: "<@U123>"}
+	assert.Equal(t, "`&lt;!channel&gt;`: `a&amp;b` → `&lt;@U123&gt;`", change.Format("`", EscapeMrkdwn))
+	assert.Equal(t, "`<!channel>`: `a&b` → `<@U123>`", change.Format("`", Verbatim))
+
+	change = KeyChange{Key: "a`b", Kind: Added, NewValue: "`@everyone`"}
+	assert.Equal(t, "`aˋb`: `ˋ@everyoneˋ`", change.Format("`", EscapeBackticks))
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "héllo", Truncate("héllo", 5))
+	assert.Equal(t, "hél…", Truncate("héllo", 4))
+}
+
+func TestRenderFailureWithInvalidTemplate(t *testing.T) {
+	_, err := Render(sampleEvent(), "{{ .Unknown }}", false)
+	assert.Error(t, err)
+}
+
+func TestPostFailure(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid payload"))
+	}))
+	defer mockServer.Close()
+
+	assert.EqualError(t, Post("teams", mockServer.URL, map[string]string{}), "teams responded with the status code '400': invalid payload")
+}
@@ -1,43 +1,432 @@
 package commandexecutor
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	log "github.com/sirupsen/logrus"
-	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
 	"os"
 	"os/exec"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	log "github.com/sirupsen/logrus"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/dotenv"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/encryption"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/fileutil"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+type Delivery string
+
+const (
+	File  Delivery = "file"
+	Stdin Delivery = "stdin"
+	Env   Delivery = "env"
 )
 
 type CommandExecutorTarget struct {
 	Command          string
 	IntermediateFile string
+	Encryption       encryption.Settings
+	Delivery         string
+	EnvPrefix        string
+	EnvSanitize      bool
+	Timeout          time.Duration
+	SuccessCodes     string
+	RetryableCodes   string
+	Argv             string
+	Dir              string
+	EnvAllowlist     string
+	EnvOverrides     []string
+	User             string
+	Group            string
+}
+
+// keyValues collects the 'KEY=VALUE' pairs of a repeatable flag
+type keyValues []string
+
+func (k *keyValues) String() string {
+	return strings.Join(*k, ",")
+}
+
+func (k *keyValues) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("expected 'KEY=VALUE', found '%s'", value)
+	}
+	*k = append(*k, value)
+	return nil
 }
 
 func (c *CommandExecutorTarget) Args() {
 	flag.StringVar(&c.Command, "target-command", "", "Command to execute whenever the key store change is observed.")
 	flag.StringVar(&c.IntermediateFile, "intermediate-file-for-changed-keystore", "/tmp/vaultie-talkie/keystore.json", "At this file, the old and new keystore will be written in the JSON format: {'old_key_store': <old key store JSON>, 'new_key_store': <new key store JSON>}. Whenever your target command executes, it can assume that the contents of the old and new keystore would be present in this intermediate path and accordingly, use it.")
+	flag.StringVar(&c.Delivery, "target-command-keystore-delivery", string(File), "Comma separated ways of handing the key stores to the command. 'file' writes them to the intermediate file, 'stdin' pipes the same JSON to the command's standard input and 'env' exposes the new key store as environment variables of the command")
+	flag.StringVar(&c.EnvPrefix, "target-command-env-prefix", "SECRET_", "'env' delivery only. Prefix of the environment variables holding the keys of the new key store")
+	flag.BoolVar(&c.EnvSanitize, "target-command-env-sanitize-keys", true, "'env' delivery only. Turn the keys into conventional environment variable names, for example, 'db.password' becomes 'DB_PASSWORD'")
+	flag.DurationVar(&c.Timeout, "target-command-timeout", 5*time.Minute, "Duration after which the command, along with every process it spawned, gets killed. Set to 0 for no timeout")
+	flag.StringVar(&c.SuccessCodes, "target-command-success-exit-codes", "0", "Comma separated exit codes of the command meaning success")
+	flag.StringVar(&c.RetryableCodes, "target-command-retryable-exit-codes", "", "Comma separated exit codes of the command meaning a failure worth retrying. A change failing the command with any other exit code isn't retried. If left empty, every failure is retried")
+	flag.StringVar(&c.Argv, "target-command-argv", "", `Command to execute, as a JSON array of the program and its arguments like '["systemctl", "reload", "app"]', without going through a shell. Can't be used along with -target-command`)
+	flag.StringVar(&c.Dir, "target-command-dir", "", "Working directory of the command. Defaults to the working directory of vaultie-talkie")
+	flag.StringVar(&c.EnvAllowlist, "target-command-env-allowlist", "", "Comma separated names of the environment variables of vaultie-talkie passed on to the command, a trailing '*' matching any suffix like 'LC_*'. If left empty, the whole environment is passed on")
+	flag.Var((*keyValues)(&c.EnvOverrides), "target-command-env", "Environment variable, as 'KEY=VALUE', set for the command on top of everything else. Can be repeated")
+	flag.StringVar(&c.User, "target-command-user", "", "User, by name or uid, to run the command as. Requires vaultie-talkie to run as root")
+	flag.StringVar(&c.Group, "target-command-group", "", "Group, by name or gid, to run the command as. Defaults to the primary group of -target-command-user. Requires vaultie-talkie to run as root")
+	c.Encryption.Args("intermediate-file", "intermediate file")
+}
+
+func (c *CommandExecutorTarget) SetVaultClient(client *vault.Client) {
+	c.Encryption.SetVaultClient(client)
 }
 
 func (c *CommandExecutorTarget) Execute(oldKeyStore, newKeyStore target.KeyStore) error {
-	intermediateFileContents := map[string]target.KeyStore{
+	return c.ExecuteEvent(target.Event{OldKeyStore: oldKeyStore, NewKeyStore: newKeyStore})
+}
+
+func (c *CommandExecutorTarget) ExecuteEvent(event target.Event) error {
+	oldKeyStore, newKeyStore := event.OldKeyStore, event.NewKeyStore
+	deliveries, err := c.deliveries()
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(map[string]target.KeyStore{
 		"old_key_store": oldKeyStore,
 		"new_key_store": newKeyStore,
-	}
-	intermediateFileContentsBytes, err := json.Marshal(intermediateFileContents)
+	})
 	if err != nil {
 		return fmt.Errorf("error occurred while marshalling the intermediate file contents into JSON format: %w", err)
 	}
-	log.Debug("Writing the old and new key store to the intemediate file at the path", c.IntermediateFile)
-	if err := os.WriteFile(c.IntermediateFile, intermediateFileContentsBytes, 0644); err != nil {
-		return fmt.Errorf("error occurred while writing to the intermediate file at the path '%s': %w", c.IntermediateFile, err)
+
+	cmd, name, err := c.command()
+	if err != nil {
+		return err
 	}
+	uid, gid, err := credentials(c.User, c.Group)
+	if err != nil {
+		return err
+	}
+	if err := runAs(cmd, uid, gid); err != nil {
+		return err
+	}
+	if deliveries[File] {
+		if err := c.writeIntermediateFile(payload, uid, gid); err != nil {
+			return err
+		}
+	}
+	if deliveries[Stdin] {
+		cmd.Stdin = bytes.NewReader(payload)
+	}
+	env := allowedEnv(os.Environ(), c.EnvAllowlist)
+	if deliveries[Env] {
+		secrets, err := EnvVars(newKeyStore, c.EnvPrefix, c.EnvSanitize)
+		if err != nil {
+			return err
+		}
+		env = append(env, secrets...)
+	}
+	env = append(env, eventEnvVars(event)...)
+	// later entries win over the earlier ones with the same name
+	cmd.Env = append(env, c.EnvOverrides...)
+
+	logger := log.WithFields(event.Fields()).WithField("command", name)
+	logger.Debug("Executing the command")
+	return c.run(cmd, name, logger)
+}
 
-	log.Debug("Executing the following command", c.Command)
-	if _, err := exec.Command("sh", "-c", c.Command).Output(); err != nil {
-		return fmt.Errorf("error occurred while executing the target command '%s': %w", c.Command, err)
+// RunCommand runs the command with the environment of vaultie-talkie, and none of the key stores handed to it, bound by
+// the same timeout and exit codes as the target's command
+func (c *CommandExecutorTarget) RunCommand(logger *log.Entry) error {
+	cmd, name, err := c.command()
+	if err != nil {
+		return err
 	}
+	logger = logger.WithField("command", name)
+	logger.Debug("Executing the command")
+	return c.run(cmd, name, logger)
+}
 
+// eventEnvVars tell the command which change it's executed for
+func eventEnvVars(event target.Event) []string {
+	env := []string{}
+	if event.ID != "" {
+		env = append(env, "VAULTIE_TALKIE_EVENT_ID="+event.ID)
+	}
+	if event.Path != "" {
+		env = append(env, "VAULTIE_TALKIE_PATH="+event.Path)
+	}
+	if event.Version > 0 {
+		env = append(env, "VAULTIE_TALKIE_VERSION="+strconv.Itoa(event.Version))
+	}
+	return env
+}
+
+// command builds the command out of either its argv form, run as is, or its shell form, run through 'sh -c'
+func (c *CommandExecutorTarget) command() (*exec.Cmd, string, error) {
+	var cmd *exec.Cmd
+	switch {
+	case c.Command != "" && c.Argv != "":
+		return nil, "", fmt.Errorf("only one of the shell command and the argv command is expected to be provided")
+	case c.Argv != "":
+		argv := []string{}
+		if err := json.Unmarshal([]byte(c.Argv), &argv); err != nil {
+			return nil, "", fmt.Errorf("error occurred while parsing the argv command '%s', expected a JSON array of strings: %w", c.Argv, err)
+		}
+		if len(argv) == 0 || argv[0] == "" {
+			return nil, "", fmt.Errorf("no program found in the argv command '%s'", c.Argv)
+		}
+		cmd = exec.Command(argv[0], argv[1:]...)
+	default:
+		cmd = exec.Command("sh", "-c", c.Command)
+	}
+	cmd.Dir = c.Dir
+	name := c.Command
+	if c.Argv != "" {
+		name = c.Argv
+	}
+	return cmd, name, nil
+}
+
+// allowedEnv keeps the 'NAME=value' entries whose name is allowed, every entry being allowed if the allowlist is empty
+func allowedEnv(environ []string, allowlist string) []string {
+	if strings.TrimSpace(allowlist) == "" {
+		return environ
+	}
+	allowed := []string{}
+	for _, entry := range environ {
+		name := strings.SplitN(entry, "=", 2)[0]
+		for _, pattern := range strings.Split(allowlist, ",") {
+			pattern = strings.TrimSpace(pattern)
+			if name == pattern || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(name, strings.TrimSuffix(pattern, "*"))) {
+				allowed = append(allowed, entry)
+				break
+			}
+		}
+	}
+	return allowed
+}
+
+// how long the output of a command, which exited or got killed, is read for before giving up on it
+var waitDelay = 5 * time.Second
+
+// run streams the command's output to the logs, kills it along with its children upon the timeout
+// and judges its exit code as per the success and retryable exit codes
+func (c *CommandExecutorTarget) run(cmd *exec.Cmd, name string, logger *log.Entry) error {
+	successCodes, err := parseExitCodes(c.SuccessCodes, "0")
+	if err != nil {
+		return err
+	}
+	retryableCodes, err := parseExitCodes(c.RetryableCodes, "")
+	if err != nil {
+		return err
+	}
+
+	tail := &outputTail{}
+	stdout := &streamWriter{logger: logger.WithField("stream", "stdout"), tail: tail}
+	stderr := &streamWriter{logger: logger.WithField("stream", "stderr"), tail: tail}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	// bounds the wait for the output once the command exited, as whatever it left running may hold the output open
+	cmd.WaitDelay = waitDelay
+	startInNewProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error occurred while starting the target command '%s': %w", name, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	var timeout <-chan time.Time
+	if c.Timeout > 0 {
+		timer := time.NewTimer(c.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	timedOut := false
+	select {
+	case err = <-done:
+	case <-timeout:
+		timedOut = true
+		if err := killProcessGroup(cmd); err != nil {
+			logger.Warnf("failed to kill the command which timed out: %s", err)
+		}
+		err = <-done
+	}
+	stdout.flush()
+	stderr.flush()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		logger.Warnf("stopped reading the output of the target command '%s' after %s as the processes it left running hold it open", name, waitDelay)
+		err = nil
+	}
+
+	if timedOut {
+		return fmt.Errorf("target command '%s' timed out after %s and got killed%s", name, c.Timeout, outputSuffix(tail))
+	}
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return fmt.Errorf("error occurred while executing the target command '%s': %w", name, err)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+	if successCodes[exitCode] {
+		return nil
+	}
+	if err == nil {
+		err = fmt.Errorf("exit status %d isn't among the success exit codes", exitCode)
+	}
+	err = fmt.Errorf("error occurred while executing the target command '%s': %w%s", name, err, outputSuffix(tail))
+	if len(retryableCodes) > 0 && !retryableCodes[exitCode] {
+		return target.PermanentError{Err: err}
+	}
+	return err
+}
+
+func outputSuffix(tail *outputTail) string {
+	if output := tail.String(); output != "" {
+		return ", output: " + output
+	}
+	return ""
+}
+
+func parseExitCodes(codes, defaultCodes string) (map[int]bool, error) {
+	if strings.TrimSpace(codes) == "" {
+		codes = defaultCodes
+	}
+	parsed := map[int]bool{}
+	for _, code := range strings.Split(codes, ",") {
+		if code = strings.TrimSpace(code); code == "" {
+			continue
+		}
+		exitCode, err := strconv.Atoi(code)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exit code '%s' found: %w", code, err)
+		}
+		parsed[exitCode] = true
+	}
+	return parsed, nil
+}
+
+func (c *CommandExecutorTarget) deliveries() (map[Delivery]bool, error) {
+	deliveries := map[Delivery]bool{}
+	delivery := c.Delivery
+	if delivery == "" {
+		delivery = string(File)
+	}
+	for _, d := range strings.Split(delivery, ",") {
+		switch d = strings.TrimSpace(d); Delivery(d) {
+		case File, Stdin, Env:
+			deliveries[Delivery(d)] = true
+		default:
+			return nil, fmt.Errorf("unknown key store delivery '%s' found. Currently, allowed deliveries are 'file', 'stdin', 'env'", d)
+		}
+	}
+	return deliveries, nil
+}
+
+func (c *CommandExecutorTarget) writeIntermediateFile(payload []byte, uid, gid int) error {
+	payload, err := c.Encryption.Encrypt(payload)
+	if err != nil {
+		return fmt.Errorf("error occurred while encrypting the intermediate file contents: %w", err)
+	}
+	// the intermediate file holds every secret, hence, neither the file nor the directories created for it are readable by others
+	dir := filepath.Dir(c.IntermediateFile)
+	created, err := mkdirs(dir)
+	if err != nil {
+		return err
+	}
+	// except for the user the command runs as. The directories which existed already are left alone, as they may well be shared, like /tmp.
+	opts := fileutil.DefaultWriteOptions(0600)
+	opts.Uid, opts.Gid = uid, gid
+	if uid >= 0 || gid >= 0 {
+		for _, d := range created {
+			if err := os.Chown(d, uid, gid); err != nil {
+				return fmt.Errorf("error occurred while setting the owner of the directory '%s' of the intermediate file: %w", d, err)
+			}
+		}
+	}
+	log.WithField("intermediate_file", c.IntermediateFile).Debug("Writing the old and new key store to the intermediate file")
+	if err := fileutil.WriteAtomic(c.IntermediateFile, payload, opts); err != nil {
+		return fmt.Errorf("error occurred while writing to the intermediate file at the path '%s': %w", c.IntermediateFile, err)
+	}
 	return nil
 }
+
+// mkdirs creates the directory along with its missing parents, and returns the ones it created
+func mkdirs(dir string) ([]string, error) {
+	missing := []string{}
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error occurred while inspecting the directory '%s': %w", d, err)
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error occurred while creating the directory of the intermediate file '%s': %w", dir, err)
+	}
+	return missing, nil
+}
+
+// credentials resolves the user and group to run the command as, by name or id. The group defaults to the primary one
+// of the user, rather than the one of vaultie-talkie, so that the privileges never get only half dropped.
+func credentials(username, group string) (int, int, error) {
+	uid, gid, err := fileutil.LookupIds(username, group)
+	if err != nil || uid < 0 || gid >= 0 {
+		return uid, gid, err
+	}
+	u, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		return -1, -1, fmt.Errorf("error occurred while looking up the primary group of the user '%s', consider setting the group explicitly: %w", username, err)
+	}
+	if gid, err = strconv.Atoi(u.Gid); err != nil {
+		return -1, -1, fmt.Errorf("user '%s' doesn't have a numeric primary gid: %s", username, u.Gid)
+	}
+	return uid, gid, nil
+}
+
+// EnvVars renders the key store as 'NAME=value' environment variables, sorted by name, nested values being rendered as JSON
+func EnvVars(keyStore target.KeyStore, prefix string, sanitize bool) ([]string, error) {
+	vars := map[string]string{}
+	originalKeys := map[string]string{}
+	for key, value := range keyStore {
+		name := key
+		if sanitize {
+			name = dotenv.SanitizeKey(key)
+		}
+		name = prefix + name
+		if strings.ContainsAny(name, "=\x00") || name == "" {
+			return nil, fmt.Errorf("key '%s' can't be used as an environment variable name, consider sanitizing the keys", key)
+		}
+		if original, ok := originalKeys[name]; ok {
+			return nil, fmt.Errorf("keys '%s' and '%s' both end up as the environment variable '%s'", original, key, name)
+		}
+		originalKeys[name] = key
+		formatted, err := dotenv.FormatValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("error occurred while formatting the value of the key '%s': %w", key, err)
+		}
+		vars[name] = name + "=" + formatted
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	env := make([]string, 0, len(names))
+	for _, name := range names {
+		env = append(env, vars[name])
+	}
+	return env, nil
+}
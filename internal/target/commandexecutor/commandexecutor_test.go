@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
 )
@@ -118,3 +121,152 @@ func TestCommandExecutorFailureWithWrongCommand(t *testing.T) {
 	assert.NoError(t, intermediateFile.teardown())
 	assert.NoFileExists(t, intermediateFilePath)
 }
+
+func TestCommandExecutorSuccessWithStdinAndEnvDelivery(t *testing.T) {
+	dir := t.TempDir()
+	ct := CommandExecutorTarget{
+		Command:          fmt.Sprintf("cat > %s/stdin.json && env | grep '^SECRET_' | sort > %s/env.txt", dir, dir),
+		IntermediateFile: path.Join(dir, "never-written.json"),
+		Delivery:         "stdin, env",
+		EnvPrefix:        "SECRET_",
+		EnvSanitize:      true,
+	}
+	oldKeyStore := target.KeyStore(map[string]interface{}{
+		"foo": "bar",
+	})
+	newKeyStore := target.KeyStore(map[string]interface{}{
+		"db.password": "s3 cr3t",
+		"servers":     []interface{}{"a", "b"},
+	})
+	assert.NoError(t, ct.Execute(oldKeyStore, newKeyStore))
+	assert.NoFileExists(t, ct.IntermediateFile)
+
+	stdinContentsBytes, err := os.ReadFile(path.Join(dir, "stdin.json"))
+	assert.NoError(t, err)
+	expectedStdinContentsBytes, err := json.Marshal(map[string]interface{}{
+		"old_key_store": oldKeyStore,
+		"new_key_store": newKeyStore,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, string(expectedStdinContentsBytes), string(stdinContentsBytes))
+
+	envContentsBytes, err := os.ReadFile(path.Join(dir, "env.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "SECRET_DB_PASSWORD=s3 cr3t\nSECRET_SERVERS=[\"a\",\"b\"]\n", string(envContentsBytes))
+}
+
+func TestCommandExecutorIntermediateFilePermissions(t *testing.T) {
+	ct := CommandExecutorTarget{
+		Command:          "true",
+		IntermediateFile: path.Join(t.TempDir(), "vaultie-talkie", "keystore.json"),
+	}
+	assert.NoError(t, ct.Execute(target.KeyStore{}, target.KeyStore{"a": "b"}))
+
+	info, err := os.Stat(ct.IntermediateFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(path.Dir(ct.IntermediateFile))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	ct.Delivery = "carrier-pigeon"
+	assert.EqualError(t, ct.Execute(target.KeyStore{}, target.KeyStore{}), "unknown key store delivery 'carrier-pigeon' found. Currently, allowed deliveries are 'file', 'stdin', 'env'")
+}
+
+func TestEnvVarsFailureWithCollidingKeys(t *testing.T) {
+	_, err := EnvVars(target.KeyStore{"db.user": "a", "db-user": "b"}, "", true)
+	assert.ErrorContains(t, err, "both end up as the environment variable 'DB_USER'")
+}
+
+func TestCommandExecutorFailureWithTimeout(t *testing.T) {
+	ct := CommandExecutorTarget{
+		// the background sleep holding on to stdout hangs the command unless the whole process group gets killed
+		Command:          "echo started; sleep 30 & sleep 30",
+		IntermediateFile: path.Join(t.TempDir(), "keystore.json"),
+		Timeout:          200 * time.Millisecond,
+	}
+	start := time.Now()
+	err := ct.Execute(target.KeyStore{}, target.KeyStore{"a": "b"})
+	assert.EqualError(t, err, fmt.Sprintf("target command '%s' timed out after 200ms and got killed, output: started", ct.Command))
+	assert.False(t, target.IsPermanent(err))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestCommandExecutorSuccessWithBackgroundProcess(t *testing.T) {
+	defer func(d time.Duration) { waitDelay = d }(waitDelay)
+	waitDelay = 200 * time.Millisecond
+	ct := CommandExecutorTarget{
+		// the command exits right away but the sleep it leaves running holds on to its stdout
+		Command:          "echo started; sleep 30 &",
+		IntermediateFile: path.Join(t.TempDir(), "keystore.json"),
+	}
+	start := time.Now()
+	assert.NoError(t, ct.Execute(target.KeyStore{}, target.KeyStore{"a": "b"}))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestCommandExecutorExitCodes(t *testing.T) {
+	ct := CommandExecutorTarget{
+		Command:          "echo 'reloading'; echo 'config invalid' >&2; exit 3",
+		IntermediateFile: path.Join(t.TempDir(), "keystore.json"),
+	}
+	err := ct.Execute(target.KeyStore{}, target.KeyStore{"a": "b"})
+	assert.EqualError(t, err, fmt.Sprintf("error occurred while executing the target command '%s': exit status 3, output: reloading\nconfig invalid", ct.Command))
+	assert.False(t, target.IsPermanent(err), "every failure is expected to be retryable by default")
+
+	ct.RetryableCodes = "75"
+	err = ct.Execute(target.KeyStore{}, target.KeyStore{"a": "b"})
+	assert.Error(t, err)
+	assert.True(t, target.IsPermanent(err))
+
+	ct.SuccessCodes = "0,3"
+	assert.NoError(t, ct.Execute(target.KeyStore{}, target.KeyStore{"a": "b"}))
+}
+
+func TestOutputTail(t *testing.T) {
+	tail := &outputTail{}
+	w := &streamWriter{logger: log.WithField("stream", "stdout"), tail: tail}
+	w.Write([]byte(strings.Repeat("x", outputTailSize)))
+	w.Write([]byte("partial"))
+	w.flush()
+	assert.Equal(t, strings.Repeat("x", outputTailSize-len("partial"))+"partial", tail.String())
+	assert.Empty(t, w.partial)
+}
+
+func TestCommandExecutorSuccessWithArgvDirAndEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("LC_TEST_ALLOWED", "yes")
+	t.Setenv("TEST_FORBIDDEN", "no")
+	ct := CommandExecutorTarget{
+		// no shell gets to interpret the arguments, hence, the redirection is just another argument
+		Argv:             `["sh", "-c", "pwd > out.txt; echo \"$1\" >> out.txt; env | grep TEST_ | sort >> out.txt", "argv0", "$(reboot) > /etc/passwd"]`,
+		Dir:              dir,
+		IntermediateFile: path.Join(dir, "keystore.json"),
+		EnvAllowlist:     "PATH, LC_*",
+		EnvOverrides:     []string{"TEST_OVERRIDE=1", "LC_TEST_ALLOWED=overridden"},
+	}
+	assert.NoError(t, ct.Execute(target.KeyStore{}, target.KeyStore{"a": "b"}))
+
+	outputContentsBytes, err := os.ReadFile(path.Join(dir, "out.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, dir+"\n$(reboot) > /etc/passwd\nLC_TEST_ALLOWED=overridden\nTEST_OVERRIDE=1\n", string(outputContentsBytes))
+
+	ct.Command = "true"
+	assert.EqualError(t, ct.Execute(target.KeyStore{}, target.KeyStore{}), "only one of the shell command and the argv command is expected to be provided")
+	ct.Command, ct.Argv = "", "[]"
+	assert.EqualError(t, ct.Execute(target.KeyStore{}, target.KeyStore{}), "no program found in the argv command '[]'")
+}
+
+func TestCommandExecutorSuccessWithEventEnv(t *testing.T) {
+	ct := CommandExecutorTarget{
+		Command:          `test "$VAULTIE_TALKIE_EVENT_ID" = 5c2a7a3e && test "$VAULTIE_TALKIE_PATH" = app/config && test "$VAULTIE_TALKIE_VERSION" = 7`,
+		IntermediateFile: path.Join(t.TempDir(), "keystore.json"),
+	}
+	assert.NoError(t, ct.ExecuteEvent(target.Event{
+		ID:          "5c2a7a3e",
+		Attempt:     1,
+		Path:        "app/config",
+		Version:     7,
+		NewKeyStore: target.KeyStore{"a": "b"},
+	}))
+}
@@ -0,0 +1,77 @@
+//go:build !windows
+
+package commandexecutor
+
+import (
+	"os"
+	"os/user"
+	"path"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+func TestCommandExecutorSuccessWithUser(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("dropping privileges requires running the tests as root")
+	}
+	ct := CommandExecutorTarget{
+		Command:          "id -u; id -g; id -G; exit 1",
+		IntermediateFile: path.Join(t.TempDir(), "vaultie-talkie", "keystore.json"),
+		User:             "65534",
+		Group:            "65534",
+	}
+	err := ct.Execute(target.KeyStore{}, target.KeyStore{"a": "b"})
+	assert.ErrorContains(t, err, "output: 65534\n65534\n65534")
+
+	info, err := os.Stat(ct.IntermediateFile)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(65534), info.Sys().(*syscall.Stat_t).Uid)
+}
+
+func TestCommandExecutorUserPrimaryGroup(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("dropping privileges requires running the tests as root")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("no 'nobody' user found")
+	}
+	shared := t.TempDir()
+	ct := CommandExecutorTarget{
+		Command:          "id -u; id -g; exit 1",
+		IntermediateFile: path.Join(shared, "vaultie-talkie", "keystore.json"),
+		User:             "nobody",
+	}
+	err = ct.Execute(target.KeyStore{}, target.KeyStore{"a": "b"})
+	assert.ErrorContains(t, err, "output: "+nobody.Uid+"\n"+nobody.Gid)
+
+	// only the directory created for the intermediate file is handed to the user
+	info, err := os.Stat(path.Join(shared, "vaultie-talkie"))
+	assert.NoError(t, err)
+	assert.Equal(t, nobody.Uid, strconv.Itoa(int(info.Sys().(*syscall.Stat_t).Uid)))
+	info, err = os.Stat(shared)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), info.Sys().(*syscall.Stat_t).Uid)
+}
+
+func TestCredentials(t *testing.T) {
+	current, err := user.Current()
+	assert.NoError(t, err)
+	uid, gid, err := credentials(current.Uid, "")
+	assert.NoError(t, err)
+	assert.Equal(t, current.Uid, strconv.Itoa(uid))
+	assert.Equal(t, current.Gid, strconv.Itoa(gid))
+
+	// an explicit group wins over the primary one
+	_, gid, err = credentials(current.Uid, "12345")
+	assert.NoError(t, err)
+	assert.Equal(t, 12345, gid)
+
+	uid, gid, err = credentials("", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{-1, -1}, []int{uid, gid})
+}
@@ -0,0 +1,67 @@
+package commandexecutor
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// how much of the command's latest output is kept for the error message in case it fails
+const outputTailSize = 4096
+
+// outputTail keeps the last outputTailSize bytes written to it by stdout and stderr alike
+type outputTail struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (o *outputTail) append(p []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf = append(o.buf, p...)
+	if len(o.buf) > outputTailSize {
+		o.buf = o.buf[len(o.buf)-outputTailSize:]
+	}
+}
+
+func (o *outputTail) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return strings.TrimSpace(string(o.buf))
+}
+
+// streamWriter logs, at debug level as the command may print the secrets it's handed, every complete line written
+// by the command as soon as it's written and feeds the tail
+type streamWriter struct {
+	logger  *log.Entry
+	tail    *outputTail
+	partial []byte
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	s.tail.append(p)
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		s.log(s.partial[:i])
+		s.partial = s.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush logs whatever is left after the command's last newline
+func (s *streamWriter) flush() {
+	if len(s.partial) > 0 {
+		s.log(s.partial)
+		s.partial = nil
+	}
+}
+
+func (s *streamWriter) log(line []byte) {
+	s.logger.Debug(strings.TrimRight(string(line), "\r"))
+}
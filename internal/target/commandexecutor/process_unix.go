@@ -0,0 +1,43 @@
+//go:build !windows
+
+package commandexecutor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// startInNewProcessGroup makes the command lead its own process group so that whatever it spawns can be killed along with it
+func startInNewProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	// a negative pid signals the whole process group
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// runAs drops the privileges of the command to the uid and gid, as resolved by credentials. Only the uid can be -1,
+// keeping the one of vaultie-talkie, as the gid of a user defaults to its primary one.
+func runAs(cmd *exec.Cmd, uid, gid int) error {
+	if uid < 0 && gid < 0 {
+		return nil
+	}
+	if uid < 0 {
+		uid = os.Getuid()
+	}
+	if gid < 0 {
+		return fmt.Errorf("no group found to run the command as the uid %d with", uid)
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// the supplementary groups of vaultie-talkie aren't handed down to the command
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: []uint32{}}
+	return nil
+}
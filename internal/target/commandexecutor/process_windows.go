@@ -0,0 +1,22 @@
+//go:build windows
+
+package commandexecutor
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// process groups aren't a thing on windows, hence, only the command itself is killed
+func startInNewProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+func runAs(cmd *exec.Cmd, uid, gid int) error {
+	if uid >= 0 || gid >= 0 {
+		return fmt.Errorf("running the command as a different user or group isn't supported on windows")
+	}
+	return nil
+}
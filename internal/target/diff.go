@@ -0,0 +1,40 @@
+package target
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Diff lists the keys which were added, removed or changed between two key stores, sorted alphabetically.
+type Diff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+func ComputeDiff(oldKeyStore, newKeyStore KeyStore) Diff {
+	diff := Diff{}
+	for key, newValue := range newKeyStore {
+		oldValue, ok := oldKeyStore[key]
+		if !ok {
+			diff.Added = append(diff.Added, key)
+			continue
+		}
+		if !reflect.DeepEqual(oldValue, newValue) {
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+	for key := range oldKeyStore {
+		if _, ok := newKeyStore[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
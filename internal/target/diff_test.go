@@ -0,0 +1,39 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeDiff(t *testing.T) {
+	oldKeyStore := KeyStore(map[string]interface{}{
+		"foo":     "bar",
+		"stale":   "value",
+		"nested":  map[string]interface{}{"a": "b"},
+		"same":    "same",
+		"changed": "before",
+	})
+	newKeyStore := KeyStore(map[string]interface{}{
+		"foo":     "bar",
+		"fresh":   "value",
+		"another": "value",
+		"nested":  map[string]interface{}{"a": "c"},
+		"same":    "same",
+		"changed": "after",
+	})
+
+	diff := ComputeDiff(oldKeyStore, newKeyStore)
+	assert.Equal(t, []string{"another", "fresh"}, diff.Added)
+	assert.Equal(t, []string{"stale"}, diff.Removed)
+	assert.Equal(t, []string{"changed", "nested"}, diff.Changed)
+	assert.False(t, diff.Empty())
+}
+
+func TestComputeDiffWithNoChanges(t *testing.T) {
+	keyStore := KeyStore(map[string]interface{}{
+		"foo": "bar",
+	})
+	assert.True(t, ComputeDiff(keyStore, keyStore).Empty())
+	assert.True(t, ComputeDiff(nil, nil).Empty())
+}
@@ -0,0 +1,46 @@
+package discord
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/chat"
+)
+
+// Discord rejects the messages longer than that, in runes
+const maxContentLength = 2000
+
+type DiscordTarget struct {
+	WebhookUrl      string
+	Username        string
+	MessageTemplate string
+	ShowValues      bool
+}
+
+type message struct {
+	Username string `json:"username,omitempty"`
+	Content  string `json:"content"`
+}
+
+func (d *DiscordTarget) Args() {
+	flag.StringVar(&d.WebhookUrl, "discord-webhook-url", "", "Discord webhook URL to which the summary of a key store change is posted")
+	flag.StringVar(&d.Username, "discord-username", "vaultie-talkie", "Username with which the message is posted")
+	flag.StringVar(&d.MessageTemplate, "discord-message-template", chat.DefaultMessageTemplate, "Go template for the headline of the message. Available fields: .Path, .Version, .Added, .Removed, .Changed")
+	flag.BoolVar(&d.ShowValues, "discord-show-values", false, "Include the old and new values of the added/removed/changed keys in the message. By default, only the key names are posted")
+}
+
+func (d DiscordTarget) Execute(oldKeyStore, newKeyStore target.KeyStore) error {
+	return d.ExecuteEvent(target.Event{OldKeyStore: oldKeyStore, NewKeyStore: newKeyStore})
+}
+
+func (d DiscordTarget) ExecuteEvent(event target.Event) error {
+	notification, err := chat.Render(event, d.MessageTemplate, d.ShowValues)
+	if err != nil {
+		return fmt.Errorf("error occurred while rendering the discord message: %w", err)
+	}
+	return chat.Post("discord", d.WebhookUrl, message{
+		Username: d.Username,
+		Content:  chat.Truncate(notification.Markdown(chat.EscapeBackticks), maxContentLength),
+	})
+}
@@ -0,0 +1,97 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/chat"
+)
+
+type mockDiscordServer struct {
+	returnResponseStatusCode int
+	mockServer               *httptest.Server
+	receivedMessages         []message
+}
+
+func (m *mockDiscordServer) setup() {
+	m.mockServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := message{}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m.receivedMessages = append(m.receivedMessages, msg)
+		w.WriteHeader(m.returnResponseStatusCode)
+	}))
+}
+
+func (m *mockDiscordServer) teardown() {
+	if m.mockServer != nil {
+		m.mockServer.Close()
+	}
+}
+
+func TestDiscordTargetSuccess(t *testing.T) {
+	m := mockDiscordServer{returnResponseStatusCode: 204}
+	m.setup()
+	defer m.teardown()
+
+	dt := DiscordTarget{
+		WebhookUrl:      m.mockServer.URL,
+		Username:        "vaultie-talkie",
+		MessageTemplate: chat.DefaultMessageTemplate,
+	}
+	event := target.Event{
+		Path:        "applications/ecommerce",
+		Version:     5,
+		OldKeyStore: target.KeyStore(map[string]interface{}{"foo": "bar"}),
+		NewKeyStore: target.KeyStore(map[string]interface{}{"foo": "baz-secret"}),
+	}
+	assert.NoError(t, dt.ExecuteEvent(event))
+	assert.Equal(t, []message{{
+		Username: "vaultie-talkie",
+		Content:  "Secret at `applications/ecommerce` changed (version 5): 0 added, 0 removed, 1 changed\n\n**Path:** `applications/ecommerce`\n**Version:** 5\n\n**Changed**\n- `foo`",
+	}}, m.receivedMessages)
+}
+
+func TestDiscordTargetEscapesBackticks(t *testing.T) {
+	m := mockDiscordServer{returnResponseStatusCode: 204}
+	m.setup()
+	defer m.teardown()
+
+	dt := DiscordTarget{WebhookUrl: m.mockServer.URL, MessageTemplate: chat.DefaultMessageTemplate, ShowValues: true}
+	assert.NoError(t, dt.Execute(nil, target.KeyStore{"a`b": "x` @everyone `y"}))
+	assert.Len(t, m.receivedMessages, 1)
+	assert.True(t, strings.HasSuffix(m.receivedMessages[0].Content, "\n- `aˋb`: `xˋ @everyone ˋy`"), m.receivedMessages[0].Content)
+}
+
+func TestDiscordTargetTruncatesLongMessages(t *testing.T) {
+	m := mockDiscordServer{returnResponseStatusCode: 204}
+	m.setup()
+	defer m.teardown()
+
+	newKeyStore := target.KeyStore{}
+	for i := 0; i < 500; i++ {
+		newKeyStore[fmt.Sprintf("key-%03d", i)] = "value"
+	}
+	dt := DiscordTarget{WebhookUrl: m.mockServer.URL}
+	assert.NoError(t, dt.Execute(nil, newKeyStore))
+	assert.Len(t, m.receivedMessages, 1)
+	assert.Len(t, []rune(m.receivedMessages[0].Content), maxContentLength)
+	assert.True(t, strings.HasSuffix(m.receivedMessages[0].Content, "…"))
+}
+
+func TestDiscordTargetFailure(t *testing.T) {
+	m := mockDiscordServer{returnResponseStatusCode: 401}
+	m.setup()
+	defer m.teardown()
+
+	dt := DiscordTarget{WebhookUrl: m.mockServer.URL}
+	assert.EqualError(t, dt.Execute(nil, target.KeyStore(map[string]interface{}{"foo": "bar"})), "discord responded with the status code '401': ")
+}
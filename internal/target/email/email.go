@@ -0,0 +1,269 @@
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+const (
+	defaultSubjectTemplate = "[vaultie-talkie] secret at {{ .Path }} changed"
+	defaultBodyTemplate    = `The secret at the path '{{ .Path }}' changed (version {{ .Version }}): {{ len .Added }} key(s) added, {{ len .Removed }} removed, {{ len .Changed }} changed.
+{{ with .RedactedDiff }}
+{{ . }}
+{{ end }}`
+)
+
+type TLSMode string
+
+const (
+	NoTLS       TLSMode = "none"
+	StartTLS    TLSMode = "starttls"
+	ImplicitTLS TLSMode = "tls"
+)
+
+type EmailTarget struct {
+	Host            string
+	Port            int64
+	Username        string
+	Password        string
+	TLSMode         string
+	SkipTLSVerify   bool
+	From            string
+	To              string
+	SubjectTemplate string
+	BodyTemplate    string
+	IncludeDiff     bool
+	// Timeout bounds the whole exchange with the SMTP server, from connecting to quitting
+	Timeout time.Duration
+}
+
+const defaultTimeout = 30 * time.Second
+
+type messageData struct {
+	Path         string
+	Version      int
+	RedactedDiff string
+	target.Diff
+}
+
+func (e *EmailTarget) Args() {
+	flag.StringVar(&e.Host, "email-smtp-host", "", "Host of the SMTP server through which the notification email is sent")
+	flag.Int64Var(&e.Port, "email-smtp-port", 587, "Port at which the SMTP server is listening")
+	flag.StringVar(&e.Username, "email-smtp-username", "", "Username to authenticate against the SMTP server with. Authentication is skipped if left empty")
+	flag.StringVar(&e.Password, "email-smtp-password", "", "Password to authenticate against the SMTP server with")
+	flag.StringVar(&e.TLSMode, "email-smtp-tls", string(StartTLS), "TLS mode used to talk to the SMTP server. Allowed values are 'starttls', 'tls' (implicit TLS, usually on port 465) and 'none'")
+	flag.BoolVar(&e.SkipTLSVerify, "email-smtp-skip-tls-verify", false, "Skip the verification of the SMTP server's TLS certificate")
+	flag.StringVar(&e.From, "email-from", "", "Address the notification email is sent from")
+	flag.StringVar(&e.To, "email-to", "", "Comma-separated list of addresses the notification email is sent to")
+	flag.StringVar(&e.SubjectTemplate, "email-subject-template", defaultSubjectTemplate, "Go template for the subject of the email. Available fields: .Path, .Version, .Added, .Removed, .Changed, .RedactedDiff")
+	flag.StringVar(&e.BodyTemplate, "email-body-template", defaultBodyTemplate, "Go template for the plain-text body of the email. Available fields: .Path, .Version, .Added, .Removed, .Changed, .RedactedDiff")
+	flag.BoolVar(&e.IncludeDiff, "email-include-diff", false, "Include a diff of the key store, with every value redacted, in the email as .RedactedDiff")
+	flag.DurationVar(&e.Timeout, "email-smtp-timeout", defaultTimeout, "Duration after which sending the email, from connecting to the SMTP server to quitting, is given up on")
+}
+
+func (e EmailTarget) Execute(oldKeyStore, newKeyStore target.KeyStore) error {
+	return e.ExecuteEvent(target.Event{OldKeyStore: oldKeyStore, NewKeyStore: newKeyStore})
+}
+
+func (e EmailTarget) ExecuteEvent(event target.Event) error {
+	recipients := e.recipients()
+	if len(recipients) == 0 {
+		return fmt.Errorf("no recipients found to be provided for the email target")
+	}
+	from, err := parseAddress(e.From)
+	if err != nil {
+		return err
+	}
+	rcpts := []string{}
+	for _, recipient := range recipients {
+		rcpt, err := parseAddress(recipient)
+		if err != nil {
+			return err
+		}
+		rcpts = append(rcpts, rcpt.Address)
+	}
+	msg, err := e.renderMessage(event, recipients)
+	if err != nil {
+		return fmt.Errorf("error occurred while rendering the email: %w", err)
+	}
+
+	client, err := e.dial()
+	if err != nil {
+		return fmt.Errorf("error occurred while connecting to the SMTP server at '%s:%d': %w", e.Host, e.Port, err)
+	}
+	defer client.Close()
+
+	if TLSMode(e.TLSMode) == StartTLS {
+		if err := client.StartTLS(e.tlsConfig()); err != nil {
+			return fmt.Errorf("error occurred while upgrading the SMTP connection with STARTTLS: %w", err)
+		}
+	}
+	if e.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host)); err != nil {
+			return fmt.Errorf("error occurred while authenticating against the SMTP server: %w", err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("error occurred while setting the sender '%s': %w", e.From, err)
+	}
+	for _, recipient := range rcpts {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("error occurred while adding the recipient '%s': %w", recipient, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("error occurred while starting to send the email contents: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("error occurred while sending the email contents: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error occurred while finishing sending the email contents: %w", err)
+	}
+	log.WithFields(event.Fields()).Debugf("Email notification sent to %s", strings.Join(recipients, ", "))
+	return client.Quit()
+}
+
+func (e EmailTarget) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(e.Host, fmt.Sprint(e.Port))
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	// a server stalling at any point of the exchange can't hold the target up
+	deadline := time.Now().Add(timeout)
+	dialer := &net.Dialer{Deadline: deadline}
+	var (
+		conn net.Conn
+		err  error
+	)
+	switch TLSMode(e.TLSMode) {
+	case ImplicitTLS:
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, e.tlsConfig())
+	case StartTLS, NoTLS:
+		conn, err = dialer.Dial("tcp", addr)
+	default:
+		return nil, fmt.Errorf("unknown TLS mode '%s' found. Currently, allowed modes are 'starttls', 'tls', 'none'", e.TLSMode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	client, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func (e EmailTarget) tlsConfig() *tls.Config {
+	return &tls.Config{
+		ServerName:         e.Host,
+		InsecureSkipVerify: e.SkipTLSVerify,
+	}
+}
+
+// parseAddress validates the address, like 'jane@example.com' or 'Jane <jane@example.com>', so that it can't
+// smuggle extra headers into the email
+func parseAddress(address string) (*mail.Address, error) {
+	if strings.ContainsAny(address, "\r\n") {
+		return nil, fmt.Errorf("address %q contains a line break", address)
+	}
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address '%s' found: %w", address, err)
+	}
+	return parsed, nil
+}
+
+func (e EmailTarget) recipients() []string {
+	recipients := []string{}
+	for _, recipient := range strings.Split(e.To, ",") {
+		if recipient = strings.TrimSpace(recipient); recipient != "" {
+			recipients = append(recipients, recipient)
+		}
+	}
+	return recipients
+}
+
+func (e EmailTarget) renderMessage(event target.Event, recipients []string) ([]byte, error) {
+	data := messageData{
+		Path:    event.Path,
+		Version: event.Version,
+		Diff:    target.ComputeDiff(event.OldKeyStore, event.NewKeyStore),
+	}
+	if e.IncludeDiff {
+		data.RedactedDiff = renderRedactedDiff(data.Diff)
+	}
+
+	subject, err := renderTemplate("subject", e.SubjectTemplate, defaultSubjectTemplate, data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := renderTemplate("body", e.BodyTemplate, defaultBodyTemplate, data)
+	if err != nil {
+		return nil, err
+	}
+
+	subject = strings.TrimSpace(subject)
+	if strings.ContainsAny(subject, "\r\n") {
+		return nil, fmt.Errorf("rendered subject %q contains a line break", subject)
+	}
+
+	msg := new(bytes.Buffer)
+	fmt.Fprintf(msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return msg.Bytes(), nil
+}
+
+func renderTemplate(name, text, fallback string, data messageData) (string, error) {
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("error occurred while parsing the %s template: %w", name, err)
+	}
+	rendered := new(bytes.Buffer)
+	if err := tmpl.Execute(rendered, data); err != nil {
+		return "", fmt.Errorf("error occurred while executing the %s template: %w", name, err)
+	}
+	return rendered.String(), nil
+}
+
+func renderRedactedDiff(diff target.Diff) string {
+	lines := []string{}
+	for _, key := range diff.Added {
+		lines = append(lines, fmt.Sprintf("+ %s: <redacted>", key))
+	}
+	for _, key := range diff.Removed {
+		lines = append(lines, fmt.Sprintf("- %s: <redacted>", key))
+	}
+	for _, key := range diff.Changed {
+		lines = append(lines, fmt.Sprintf("~ %s: <redacted>", key))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,307 @@
+package email
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+type receivedMail struct {
+	from       string
+	recipients []string
+	auth       string
+	data       string
+	tls        bool
+}
+
+type mockSmtpServer struct {
+	tlsConfig         *tls.Config
+	rejectedRecipient string
+	listener          net.Listener
+	port              int64
+
+	mu    sync.Mutex
+	mails []receivedMail
+}
+
+func (m *mockSmtpServer) setup() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	m.listener = listener
+	m.port = int64(listener.Addr().(*net.TCPAddr).Port)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(conn)
+		}
+	}()
+	return nil
+}
+
+func (m *mockSmtpServer) teardown() error {
+	if m.listener != nil {
+		return m.listener.Close()
+	}
+	return nil
+}
+
+func (m *mockSmtpServer) receivedMails() []receivedMail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]receivedMail{}, m.mails...)
+}
+
+func (m *mockSmtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	mail := receivedMail{}
+	tp.PrintfLine("220 localhost ESMTP mock")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			if m.tlsConfig != nil && !mail.tls {
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250 STARTTLS")
+				continue
+			}
+			tp.PrintfLine("250-localhost")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			tp.PrintfLine("220 ready to start TLS")
+			tlsConn := tls.Server(conn, m.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+			mail.tls = true
+		case "AUTH":
+			mail.auth = arg
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			mail.from = strings.TrimSuffix(strings.TrimPrefix(arg, "FROM:<"), ">")
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			recipient := strings.TrimSuffix(strings.TrimPrefix(arg, "TO:<"), ">")
+			if recipient == m.rejectedRecipient {
+				tp.PrintfLine("550 no such user")
+				continue
+			}
+			mail.recipients = append(mail.recipients, recipient)
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.data = string(data)
+			m.mu.Lock()
+			m.mails = append(m.mails, mail)
+			m.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 unknown command")
+		}
+	}
+}
+
+func selfSignedTLSConfig(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{cert}, PrivateKey: key}}}
+}
+
+func sampleEvent() target.Event {
+	return target.Event{
+		Path:    "applications/ecommerce",
+		Version: 7,
+		OldKeyStore: target.KeyStore(map[string]interface{}{
+			"foo":   "bar",
+			"stale": "old-secret",
+		}),
+		NewKeyStore: target.KeyStore(map[string]interface{}{
+			"foo": "baz-secret",
+			"a":   "new-secret",
+		}),
+	}
+}
+
+func TestEmailTargetSuccess(t *testing.T) {
+	m := mockSmtpServer{}
+	assert.NoError(t, m.setup())
+	defer m.teardown()
+
+	et := EmailTarget{
+		Host:            "127.0.0.1",
+		Port:            m.port,
+		Username:        "user",
+		Password:        "pass",
+		TLSMode:         string(NoTLS),
+		From:            "vaultie@example.com",
+		To:              "alice@example.com, bob@example.com",
+		SubjectTemplate: defaultSubjectTemplate,
+		BodyTemplate:    defaultBodyTemplate,
+		IncludeDiff:     true,
+	}
+	assert.NoError(t, et.ExecuteEvent(sampleEvent()))
+
+	mails := m.receivedMails()
+	assert.Len(t, mails, 1)
+	assert.Equal(t, "vaultie@example.com", mails[0].from)
+	assert.Equal(t, []string{"alice@example.com", "bob@example.com"}, mails[0].recipients)
+	assert.Equal(t, "PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00user\x00pass")), mails[0].auth)
+
+	assert.Contains(t, mails[0].data, "Subject: [vaultie-talkie] secret at applications/ecommerce changed\n")
+	assert.Contains(t, mails[0].data, "To: alice@example.com, bob@example.com\n")
+	assert.Contains(t, mails[0].data, "The secret at the path 'applications/ecommerce' changed (version 7): 1 key(s) added, 1 removed, 1 changed.")
+	assert.Contains(t, mails[0].data, "+ a: <redacted>\n- stale: <redacted>\n~ foo: <redacted>")
+	for _, secret := range []string{"old-secret", "new-secret", "baz-secret"} {
+		assert.NotContains(t, mails[0].data, secret)
+	}
+}
+
+func TestEmailTargetSuccessWithStartTLS(t *testing.T) {
+	m := mockSmtpServer{tlsConfig: selfSignedTLSConfig(t)}
+	assert.NoError(t, m.setup())
+	defer m.teardown()
+
+	et := EmailTarget{
+		Host:            "127.0.0.1",
+		Port:            m.port,
+		TLSMode:         string(StartTLS),
+		SkipTLSVerify:   true,
+		From:            "vaultie@example.com",
+		To:              "alice@example.com",
+		SubjectTemplate: "{{ .Path }} v{{ .Version }}",
+		BodyTemplate:    "{{ .Changed }}",
+	}
+	assert.NoError(t, et.ExecuteEvent(sampleEvent()))
+
+	mails := m.receivedMails()
+	assert.Len(t, mails, 1)
+	assert.True(t, mails[0].tls)
+	assert.Empty(t, mails[0].auth)
+	assert.Contains(t, mails[0].data, "Subject: applications/ecommerce v7\n")
+	assert.True(t, strings.HasSuffix(mails[0].data, "\n\n[foo]\n"))
+}
+
+func TestEmailTargetFailureWithRejectedRecipient(t *testing.T) {
+	m := mockSmtpServer{rejectedRecipient: "nobody@example.com"}
+	assert.NoError(t, m.setup())
+	defer m.teardown()
+
+	et := EmailTarget{
+		Host:    "127.0.0.1",
+		Port:    m.port,
+		TLSMode: string(NoTLS),
+		From:    "vaultie@example.com",
+		To:      "alice@example.com,nobody@example.com",
+	}
+	assert.ErrorContains(t, et.ExecuteEvent(sampleEvent()), "error occurred while adding the recipient 'nobody@example.com': 550")
+	assert.Empty(t, m.receivedMails())
+}
+
+func TestEmailTargetFailureWithStallingServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		// accept the connection but never greet
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		time.Sleep(5 * time.Second)
+	}()
+
+	et := EmailTarget{
+		Host:    "127.0.0.1",
+		Port:    int64(listener.Addr().(*net.TCPAddr).Port),
+		TLSMode: string(NoTLS),
+		From:    "vaultie@example.com",
+		To:      "alice@example.com",
+		Timeout: 200 * time.Millisecond,
+	}
+	start := time.Now()
+	assert.ErrorContains(t, et.ExecuteEvent(sampleEvent()), "i/o timeout")
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestEmailTargetFailureWithHeaderInjection(t *testing.T) {
+	et := EmailTarget{
+		Host: "127.0.0.1",
+		From: "vaultie@example.com\r\nBcc: eve@example.com",
+		To:   "alice@example.com",
+	}
+	assert.EqualError(t, et.ExecuteEvent(sampleEvent()), `address "vaultie@example.com\r\nBcc: eve@example.com" contains a line break`)
+
+	et.From = "vaultie@example.com"
+	et.To = "alice@example.com,not an address"
+	assert.ErrorContains(t, et.ExecuteEvent(sampleEvent()), "invalid address 'not an address' found")
+
+	et.To = "alice@example.com"
+	et.SubjectTemplate = "{{ .Path }}\nBcc: eve@example.com"
+	assert.ErrorContains(t, et.ExecuteEvent(sampleEvent()), "contains a line break")
+}
+
+func TestEmailTargetFailureWithoutRecipients(t *testing.T) {
+	et := EmailTarget{
+		Host: "127.0.0.1",
+		From: "vaultie@example.com",
+		To:   " , ",
+	}
+	assert.EqualError(t, et.ExecuteEvent(sampleEvent()), "no recipients found to be provided for the email target")
+}
+
+func TestEmailTargetFailureWithUnknownTLSMode(t *testing.T) {
+	m := mockSmtpServer{}
+	assert.NoError(t, m.setup())
+	defer m.teardown()
+
+	et := EmailTarget{
+		Host:    "127.0.0.1",
+		Port:    m.port,
+		TLSMode: "gibberish",
+		From:    "vaultie@example.com",
+		To:      "alice@example.com",
+	}
+	assert.Error(t, et.ExecuteEvent(sampleEvent()))
+}
@@ -0,0 +1,22 @@
+package target
+
+import "errors"
+
+// PermanentError marks a failure which executing the target again for the same change won't fix,
+// so that the change gets skipped rather than retried.
+type PermanentError struct {
+	Err error
+}
+
+func (e PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e PermanentError) Unwrap() error {
+	return e.Err
+}
+
+func IsPermanent(err error) bool {
+	var permanent PermanentError
+	return errors.As(err, &permanent)
+}
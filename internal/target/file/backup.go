@@ -0,0 +1,143 @@
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/fileutil"
+)
+
+// sorts lexicographically in the chronological order
+const backupTimestampFormat = "20060102T150405.000000000Z"
+
+// BackupDir returns the directory the backups of the file at path are kept in, defaulting to '<path>.backups'
+func BackupDir(path, backupDir string) string {
+	if backupDir != "" {
+		return backupDir
+	}
+	return path + ".backups"
+}
+
+// backup saves the current contents of the file at path, unless they're identical to the contents about to be written,
+// and prunes all but the newest `keep` backups.
+func backup(path, backupDir string, keep int, newContents []byte) error {
+	current, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error occurred while reading the current contents of the file at the path '%s' to back them up: %w", path, err)
+	}
+	if bytes.Equal(current, newContents) {
+		return nil
+	}
+	backupPath, err := saveBackup(path, backupDir, current)
+	if err != nil {
+		return err
+	}
+	log.Debugf("Backed up the previous contents of the file at %s to %s", path, backupPath)
+	return pruneBackups(path, backupDir, keep)
+}
+
+func saveBackup(path, backupDir string, contents []byte) (string, error) {
+	dir := BackupDir(path, backupDir)
+	// backups hold secrets too, hence, only the user running vaultie-talkie gets to read them
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("error occurred while creating the backup directory '%s': %w", dir, err)
+	}
+	backupPath := filepath.Join(dir, filepath.Base(path)+"."+time.Now().UTC().Format(backupTimestampFormat))
+	if err := fileutil.WriteAtomic(backupPath, contents, fileutil.DefaultWriteOptions(0600)); err != nil {
+		return "", fmt.Errorf("error occurred while writing the backup '%s': %w", backupPath, err)
+	}
+	return backupPath, nil
+}
+
+func pruneBackups(path, backupDir string, keep int) error {
+	backups, err := ListBackups(path, backupDir)
+	if err != nil {
+		return err
+	}
+	if len(backups) <= keep {
+		return nil
+	}
+	dir := BackupDir(path, backupDir)
+	for _, name := range backups[:len(backups)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("error occurred while pruning the backup '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ListBackups lists the names of the backups of the file at path, oldest first
+func ListBackups(path, backupDir string) ([]string, error) {
+	dir := BackupDir(path, backupDir)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while listing the backup directory '%s': %w", dir, err)
+	}
+	prefix := filepath.Base(path) + "."
+	backups := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, prefix) {
+			if _, err := time.Parse(backupTimestampFormat, strings.TrimPrefix(name, prefix)); err == nil {
+				backups = append(backups, name)
+			}
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// Rollback restores the file at path to the given backup, or to the newest one if no backup is named.
+// The contents being replaced are backed up first, so that a rollback can be rolled back as well, and all but the
+// newest `keep` backups are pruned, keep being the amount of backups there were if not positive.
+func Rollback(path, backupDir, name string, keep int, opts fileutil.WriteOptions) (string, error) {
+	backups, err := ListBackups(path, backupDir)
+	if err != nil {
+		return "", err
+	}
+	if len(backups) == 0 {
+		return "", fmt.Errorf("no backups found for the file at the path '%s' under '%s'", path, BackupDir(path, backupDir))
+	}
+	if name == "" {
+		name = backups[len(backups)-1]
+	}
+	found := false
+	for _, b := range backups {
+		found = found || b == name
+	}
+	if !found {
+		return "", fmt.Errorf("backup '%s' not found for the file at the path '%s'", name, path)
+	}
+
+	contents, err := os.ReadFile(filepath.Join(BackupDir(path, backupDir), name))
+	if err != nil {
+		return "", fmt.Errorf("error occurred while reading the backup '%s': %w", name, err)
+	}
+	if current, err := os.ReadFile(path); err == nil && !bytes.Equal(current, contents) {
+		if _, err := saveBackup(path, backupDir, current); err != nil {
+			return "", err
+		}
+		if keep <= 0 {
+			keep = len(backups)
+		}
+		if err := pruneBackups(path, backupDir, keep); err != nil {
+			return "", err
+		}
+	}
+	if err := fileutil.WriteAtomic(path, contents, opts); err != nil {
+		return "", fmt.Errorf("error occurred while restoring the backup '%s' to the file at the path '%s': %w", name, path, err)
+	}
+	return name, nil
+}
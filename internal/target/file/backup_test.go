@@ -0,0 +1,82 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/fileutil"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+func TestFileTargetSuccessWithBackups(t *testing.T) {
+	m := mockDir{
+		path: "./test-filetarget",
+	}
+
+	assert.NoDirExists(t, m.path)
+	assert.NoError(t, m.setup())
+	assert.DirExists(t, m.path)
+
+	ft := FileTarget{
+		Path:    fmt.Sprintf("%s/sample.env", m.path),
+		Format:  "env",
+		Backups: 2,
+	}
+	for _, value := range []string{"one", "two", "three", "four"} {
+		assert.NoError(t, ft.Execute(target.KeyStore{}, target.KeyStore{"a": value}))
+	}
+	// re-rendering identical contents doesn't push the older backups out
+	assert.NoError(t, ft.Execute(target.KeyStore{}, target.KeyStore{"a": "four"}))
+
+	backups, err := ListBackups(ft.Path, "")
+	assert.NoError(t, err)
+	assert.Len(t, backups, 2)
+	for i, expected := range []string{"a=two", "a=three"} {
+		contents, err := os.ReadFile(filepath.Join(BackupDir(ft.Path, ""), backups[i]))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(contents))
+		info, err := os.Stat(filepath.Join(BackupDir(ft.Path, ""), backups[i]))
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+	info, err := os.Stat(BackupDir(ft.Path, ""))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	restored, err := Rollback(ft.Path, "", "", 0, fileutil.DefaultWriteOptions(0600))
+	assert.NoError(t, err)
+	assert.Equal(t, backups[1], restored)
+	contents, err := os.ReadFile(ft.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, "a=three", string(contents))
+
+	// the rolled back contents are backed up too, without growing the backups
+	backups, err = ListBackups(ft.Path, "")
+	assert.NoError(t, err)
+	assert.Len(t, backups, 2)
+	contents, err = os.ReadFile(filepath.Join(BackupDir(ft.Path, ""), backups[1]))
+	assert.NoError(t, err)
+	assert.Equal(t, "a=four", string(contents))
+	for i := 0; i < 3; i++ {
+		_, err := Rollback(ft.Path, "", "", 0, fileutil.DefaultWriteOptions(0600))
+		assert.NoError(t, err)
+	}
+	backups, err = ListBackups(ft.Path, "")
+	assert.NoError(t, err)
+	assert.Len(t, backups, 2)
+
+	_, err = Rollback(ft.Path, "", "sample.env.20000101T000000.000000000Z", 0, fileutil.DefaultWriteOptions(0600))
+	assert.EqualError(t, err, fmt.Sprintf("backup 'sample.env.20000101T000000.000000000Z' not found for the file at the path '%s'", ft.Path))
+
+	assert.NoError(t, m.teardown())
+	assert.NoDirExists(t, m.path)
+}
+
+func TestRollbackFailureWithoutBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sample.json")
+	_, err := Rollback(path, "", "", 0, fileutil.DefaultWriteOptions(0600))
+	assert.EqualError(t, err, fmt.Sprintf("no backups found for the file at the path '%s' under '%s.backups'", path, path))
+}
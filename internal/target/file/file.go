@@ -1,62 +1,261 @@
 package file
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
-	"strings"
+	"strconv"
 
+	vault "github.com/hashicorp/vault/api"
 	log "github.com/sirupsen/logrus"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/dotenv"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/encryption"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/fileutil"
 	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
 )
 
 type FileTarget struct {
-	Path   string
-	Format string
+	Path          string
+	Format        string
+	Mode          string
+	Owner         string
+	Group         string
+	FollowSymlink bool
+	EnvExport     bool
+	EnvSanitize   bool
+	WriteMode     string
+	MergePrefix   string
+	Layout        string
+	Backups       int
+	BackupDir     string
+	Encryption    encryption.Settings
+
+	// the encryption is randomized, hence, unchanged contents are told apart by the plaintext written last rather than
+	// by the contents of the file, which would be backed up and rewritten over and over otherwise
+	lastWrite *lastWrite
+}
+
+type lastWrite struct {
+	digest   [sha256.Size]byte
+	contents []byte
 }
 
+type FileLayout string
+
+const (
+	SingleFile FileLayout = "file"
+	Directory  FileLayout = "directory"
+)
+
 type FileFormat string
 
 const (
-	Env  FileFormat = "env"
-	JSON FileFormat = "json"
+	Env        FileFormat = "env"
+	JSON       FileFormat = "json"
+	YAML       FileFormat = "yaml"
+	TOML       FileFormat = "toml"
+	INI        FileFormat = "ini"
+	Properties FileFormat = "properties"
 )
 
 func (f *FileTarget) Args() {
 	flag.StringVar(&f.Path, "target-file-path", "", "Path to file where the secret contents would be saved and stored")
-	flag.StringVar(&f.Format, "target-file-format", "json", "Format in which the contents of the new keystore would be written to the target file. Currently, supported format are 'json' and 'env'")
+	flag.StringVar(&f.Layout, "target-file-layout", string(SingleFile), "'file' writes the whole key store to the file at the target file path. 'directory' projects the key store into the directory at the target file path instead, one file per key, swapping all of them at once like kubelet does for secret volumes")
+	flag.StringVar(&f.Format, "target-file-format", "json", "Format in which the contents of the new keystore would be written to the target file. Currently, supported format are 'json', 'env', 'yaml', 'toml', 'ini' and 'properties'")
+	flag.StringVar(&f.Mode, "target-file-mode", "0600", "Permissions, in octal, of the target file. The default used to be 0644, hence, set it back to 0644 if another user, like the one of the app, reads the file")
+	flag.StringVar(&f.Owner, "target-file-owner", "", "User, by name or uid, owning the target file. Defaults to the user running vaultie-talkie")
+	flag.StringVar(&f.Group, "target-file-group", "", "Group, by name or gid, owning the target file. Defaults to the group of the user running vaultie-talkie")
+	flag.BoolVar(&f.EnvExport, "target-file-env-export", false, "Prefix every line of the 'env' format with 'export ' so that the file can be sourced by a shell")
+	flag.BoolVar(&f.EnvSanitize, "target-file-env-sanitize-keys", false, "Turn the keys into conventional environment variable names in the 'env' format, for example, 'db.password' becomes 'DB_PASSWORD'")
+	flag.StringVar(&f.WriteMode, "target-file-write-mode", string(Overwrite), "How the target file is written. 'overwrite' replaces the whole file, 'merge' only adds/updates/removes the keys owned by vaultie-talkie and preserves every other line. Merging is supported for the 'env' and 'properties' formats")
+	flag.StringVar(&f.MergePrefix, "target-file-merge-key-prefix", "", "In the 'merge' write mode, keys are written with this prefix and vaultie-talkie owns every key starting with it. If left empty, vaultie-talkie owns a block of lines enclosed within marker comments instead")
+	flag.IntVar(&f.Backups, "target-file-backups", 0, "Amount of timestamped backups of the previous contents of the target file to keep. Backups are disabled with 0. Use the 'rollback' subcommand to restore one of them")
+	flag.StringVar(&f.BackupDir, "target-file-backup-dir", "", "Directory the backups of the target file are kept in. Defaults to '<target file path>.backups'")
+	flag.BoolVar(&f.FollowSymlink, "target-file-follow-symlink", false, "Write to the file the target file path links to in case the target file itself is a symlink. By default, that's refused. Symlinked directories leading to the target file are followed regardless")
+	f.Encryption.Args("target-file", "target file")
+}
+
+func (f *FileTarget) SetVaultClient(client *vault.Client) {
+	f.Encryption.SetVaultClient(client)
+}
+
+func (f *FileTarget) Execute(oldKeyStore, newKeyStore target.KeyStore) error {
+	switch FileLayout(f.Layout) {
+	case SingleFile, "":
+	case Directory:
+		return f.executeDirectory(newKeyStore)
+	default:
+		return fmt.Errorf("unknown layout '%s' found. Currently, allowed layouts are 'file', 'directory'", f.Layout)
+	}
+
+	content, err := f.render(newKeyStore)
+	if err != nil {
+		return err
+	}
+	switch WriteMode(f.WriteMode) {
+	case Overwrite, "":
+	case Merge:
+		if f.Encryption.Enabled() {
+			return fmt.Errorf("merge write mode isn't supported along with encryption")
+		}
+		if content, err = f.merge(content); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown write mode '%s' found. Currently, allowed write modes are 'overwrite', 'merge'", f.WriteMode)
+	}
+	opts, err := f.WriteOptions()
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(content))
+	current, err := os.ReadFile(f.Path)
+	if err == nil && f.lastWrite != nil && f.lastWrite.digest == digest && bytes.Equal(current, f.lastWrite.contents) {
+		log.Debugf("Contents of the file at %s didn't change, skipping writing to it", f.Path)
+		return nil
+	}
+	contentBytes, err := f.Encryption.Encrypt([]byte(content))
+	if err != nil {
+		return fmt.Errorf("error occurred while encrypting the contents of the file at the path '%s': %w", f.Path, err)
+	}
+	if f.Backups > 0 {
+		if err := backup(f.Path, f.BackupDir, f.Backups, contentBytes); err != nil {
+			return err
+		}
+	}
+	log.Debugf("Writing the new key store to the file at %s", f.Path)
+	if err := fileutil.WriteAtomic(f.Path, contentBytes, opts); err != nil {
+		return fmt.Errorf("error occurred while writing to the file at the path '%s': %w", f.Path, err)
+	}
+	f.lastWrite = &lastWrite{digest: digest, contents: contentBytes}
+	return nil
+
+}
+
+// executeDirectory projects every key into its own file, strings as they are and nested values as JSON
+func (f FileTarget) executeDirectory(keyStore target.KeyStore) error {
+	if WriteMode(f.WriteMode) == Merge {
+		return fmt.Errorf("merge write mode isn't supported for the 'directory' layout")
+	}
+	if f.Backups > 0 {
+		return fmt.Errorf("backups aren't supported for the 'directory' layout")
+	}
+	files := map[string][]byte{}
+	for key, value := range keyStore {
+		formatted, err := dotenv.FormatValue(value)
+		if err != nil {
+			return fmt.Errorf("error occurred while formatting the value of the key '%s': %w", key, err)
+		}
+		if files[key], err = f.Encryption.Encrypt([]byte(formatted)); err != nil {
+			return fmt.Errorf("error occurred while encrypting the value of the key '%s': %w", key, err)
+		}
+	}
+	opts, err := f.WriteOptions()
+	if err != nil {
+		return err
+	}
+	log.Debugf("Projecting the new key store into the directory at %s", f.Path)
+	if err := fileutil.WriteDirAtomic(f.Path, files, opts); err != nil {
+		return fmt.Errorf("error occurred while projecting the key store into the directory at the path '%s': %w", f.Path, err)
+	}
+	return nil
 }
 
-func (f FileTarget) Execute(oldKeyStore, newKeyStore target.KeyStore) error {
+func (f FileTarget) render(keyStore target.KeyStore) (string, error) {
+	prefix := ""
+	if WriteMode(f.WriteMode) == Merge {
+		prefix = f.MergePrefix
+	}
 	var content string
 	switch f.Format {
 	case string(Env):
-		content = renderKeyStoreToEnvFormat(newKeyStore)
+		var err error
+		content, err = renderKeyStoreToEnvFormat(keyStore, dotenv.Options{Export: f.EnvExport, SanitizeKeys: f.EnvSanitize, Prefix: prefix})
+		if err != nil {
+			return "", fmt.Errorf("failed to render the key store the env format: %w", err)
+		}
 	case string(JSON):
 		var err error
-		content, err = renderKeyStoreToJsonFormat(newKeyStore)
+		content, err = renderKeyStoreToJsonFormat(keyStore)
+		if err != nil {
+			return "", fmt.Errorf("failed to render the key store the JSON format: %w", err)
+		}
+	case string(YAML):
+		var err error
+		content, err = renderKeyStoreToYamlFormat(keyStore)
+		if err != nil {
+			return "", fmt.Errorf("failed to render the key store the YAML format: %w", err)
+		}
+	case string(TOML):
+		var err error
+		content, err = renderKeyStoreToTomlFormat(keyStore)
 		if err != nil {
-			return fmt.Errorf("failed to render the key store the JSON format: %w", err)
+			return "", fmt.Errorf("failed to render the key store the TOML format: %w", err)
 		}
+	case string(INI):
+		var err error
+		content, err = renderKeyStoreToIniFormat(keyStore)
+		if err != nil {
+			return "", fmt.Errorf("failed to render the key store the INI format: %w", err)
+		}
+	case string(Properties):
+		if prefix != "" {
+			prefixed := target.KeyStore{}
+			for key, value := range keyStore {
+				prefixed[prefix+key] = value
+			}
+			keyStore = prefixed
+		}
+		content = renderKeyStoreToPropertiesFormat(keyStore)
 	default:
-		return fmt.Errorf("unknown target format '%s' found. Currently, allowed formats are 'env', 'json'", f.Format)
+		return "", fmt.Errorf("unknown target format '%s' found. Currently, allowed formats are 'env', 'json', 'yaml', 'toml', 'ini', 'properties'", f.Format)
 	}
-	log.Debugf("Writing the following content to the file at %s: %s", f.Path, content)
-	contentBytes := []byte(content)
-	if err := os.WriteFile(f.Path, contentBytes, 0644); err != nil {
-		return fmt.Errorf("error occurred while writing to the file at the path '%s': %w", f.Path, err)
-	}
-	return nil
+	return content, nil
+}
 
+// merge merges the rendered contents into the current contents of the target file, as per the 'merge' write mode
+func (f FileTarget) merge(rendered string) (string, error) {
+	format := FileFormat(f.Format)
+	if format != Env && format != Properties {
+		return "", fmt.Errorf("merge write mode isn't supported for the '%s' format. Currently, it's supported for 'env', 'properties'", f.Format)
+	}
+	existing, err := os.ReadFile(f.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("error occurred while reading the current contents of the file at the path '%s': %w", f.Path, err)
+	}
+	if f.MergePrefix == "" {
+		return mergeWithMarkerBlock(string(existing), rendered), nil
+	}
+	return mergeByKeyPrefix(string(existing), rendered, f.MergePrefix, format), nil
 }
 
-func renderKeyStoreToEnvFormat(keyStore target.KeyStore) string {
-	content := ""
-	for field, value := range keyStore {
-		content += fmt.Sprintf("%s=%s\n", field, value)
+func (f FileTarget) WriteOptions() (fileutil.WriteOptions, error) {
+	modeString := f.Mode
+	if modeString == "" {
+		modeString = "0600"
+	}
+	mode, err := strconv.ParseUint(modeString, 8, 32)
+	if err != nil {
+		return fileutil.WriteOptions{}, fmt.Errorf("invalid file mode '%s' found, expected an octal number like 0600: %w", f.Mode, err)
 	}
-	return strings.TrimSuffix(content, "\n")
+	uid, gid, err := fileutil.LookupIds(f.Owner, f.Group)
+	if err != nil {
+		return fileutil.WriteOptions{}, err
+	}
+	return fileutil.WriteOptions{
+		Perm:          os.FileMode(mode),
+		Uid:           uid,
+		Gid:           gid,
+		CreateDirs:    true,
+		FollowSymlink: f.FollowSymlink,
+	}, nil
+}
+
+func renderKeyStoreToEnvFormat(keyStore target.KeyStore, opts dotenv.Options) (string, error) {
+	return dotenv.Encode(keyStore, opts)
 }
 
 func renderKeyStoreToJsonFormat(keyStore target.KeyStore) (string, error) {
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/encryption"
 	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
 )
 
@@ -119,8 +120,189 @@ func TestFileTargetFailureWithWrongFormat(t *testing.T) {
 		"foo": "bar",
 		"a":   "b",
 	})
-	assert.Error(t, ft.Execute(oldKeyStore, newKeyStore), fmt.Sprintf("unknown target format '%s' found. Currently, allowed formats are 'env', 'json'", ft.Format))
+	assert.Error(t, ft.Execute(oldKeyStore, newKeyStore), fmt.Sprintf("unknown target format '%s' found. Currently, allowed formats are 'env', 'json', 'yaml', 'toml', 'ini', 'properties'", ft.Format))
 
 	assert.NoError(t, m.teardown())
 	assert.NoDirExists(t, m.path)
 }
+
+func TestFileTargetSuccessWithPermissionsAndParentDirs(t *testing.T) {
+	m := mockDir{
+		path: "./test-filetarget",
+	}
+
+	assert.NoDirExists(t, m.path)
+	assert.NoError(t, m.setup())
+	assert.DirExists(t, m.path)
+
+	ft := FileTarget{
+		Path:   fmt.Sprintf("%s/nested/dir/sample.json", m.path),
+		Format: "json",
+		Mode:   "0640",
+		Owner:  fmt.Sprint(os.Getuid()),
+		Group:  fmt.Sprint(os.Getgid()),
+	}
+	newKeyStore := target.KeyStore(map[string]interface{}{
+		"foo": "bar",
+	})
+	assert.NoError(t, ft.Execute(target.KeyStore{}, newKeyStore))
+
+	info, err := os.Stat(ft.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	ft.Mode = ""
+	assert.NoError(t, ft.Execute(newKeyStore, target.KeyStore{}))
+	info, err = os.Stat(ft.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	assert.NoError(t, m.teardown())
+	assert.NoDirExists(t, m.path)
+}
+
+func TestFileTargetFailureWithSymlink(t *testing.T) {
+	m := mockDir{
+		path: "./test-filetarget",
+	}
+
+	assert.NoDirExists(t, m.path)
+	assert.NoError(t, m.setup())
+	assert.DirExists(t, m.path)
+
+	realPath := fmt.Sprintf("%s/real.json", m.path)
+	assert.NoError(t, os.WriteFile(realPath, []byte("{}"), 0600))
+	linkPath := fmt.Sprintf("%s/link.json", m.path)
+	assert.NoError(t, os.Symlink("real.json", linkPath))
+
+	ft := FileTarget{
+		Path:   linkPath,
+		Format: "json",
+	}
+	newKeyStore := target.KeyStore(map[string]interface{}{
+		"foo": "bar",
+	})
+	assert.EqualError(t, ft.Execute(target.KeyStore{}, newKeyStore), fmt.Sprintf("error occurred while writing to the file at the path '%s': refusing to write to '%s' as it is a symlink", linkPath, linkPath))
+
+	ft.FollowSymlink = true
+	assert.NoError(t, ft.Execute(target.KeyStore{}, newKeyStore))
+	writtenFileContentsBytes, err := os.ReadFile(realPath)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"foo":"bar"}`, string(writtenFileContentsBytes))
+
+	assert.NoError(t, m.teardown())
+	assert.NoDirExists(t, m.path)
+}
+
+func TestFileTargetSuccessWithEnvFormatOptions(t *testing.T) {
+	m := mockDir{
+		path: "./test-filetarget",
+	}
+
+	assert.NoDirExists(t, m.path)
+	assert.NoError(t, m.setup())
+	assert.DirExists(t, m.path)
+
+	ft := FileTarget{
+		Path:        fmt.Sprintf("%s/sample.env", m.path),
+		Format:      "env",
+		EnvExport:   true,
+		EnvSanitize: true,
+	}
+	newKeyStore := target.KeyStore(map[string]interface{}{
+		"db.password": "pa ss#word",
+		"db.host":     "localhost",
+		"pool":        map[string]interface{}{"size": 10},
+	})
+	assert.NoError(t, ft.Execute(target.KeyStore{}, newKeyStore))
+
+	writtenFileContentsBytes, err := os.ReadFile(ft.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, "export DB_HOST=localhost\nexport DB_PASSWORD='pa ss#word'\nexport POOL='{\"size\":10}'", string(writtenFileContentsBytes))
+
+	assert.NoError(t, m.teardown())
+	assert.NoDirExists(t, m.path)
+}
+
+func TestFileTargetSuccessWithDirectoryLayout(t *testing.T) {
+	m := mockDir{
+		path: "./test-filetarget",
+	}
+
+	assert.NoDirExists(t, m.path)
+	assert.NoError(t, m.setup())
+	assert.DirExists(t, m.path)
+
+	ft := FileTarget{
+		Path:   fmt.Sprintf("%s/secrets", m.path),
+		Layout: "directory",
+	}
+	newKeyStore := target.KeyStore(map[string]interface{}{
+		"db_password": "s3cr3t",
+		"pool":        map[string]interface{}{"size": 10},
+	})
+	assert.NoError(t, ft.Execute(target.KeyStore{}, newKeyStore))
+
+	for file, expectedContents := range map[string]string{"db_password": "s3cr3t", "pool": `{"size":10}`} {
+		writtenFileContentsBytes, err := os.ReadFile(fmt.Sprintf("%s/%s", ft.Path, file))
+		assert.NoError(t, err)
+		assert.Equal(t, expectedContents, string(writtenFileContentsBytes))
+	}
+
+	assert.NoError(t, m.teardown())
+	assert.NoDirExists(t, m.path)
+}
+
+func TestFileTargetSuccessWithEncryption(t *testing.T) {
+	publicKey, privateKey, err := encryption.GenerateKey()
+	assert.NoError(t, err)
+
+	ft := FileTarget{
+		Path:       fmt.Sprintf("%s/sample.env", t.TempDir()),
+		Format:     "env",
+		Encryption: encryption.Settings{Scheme: "nacl", Recipients: publicKey},
+	}
+	assert.NoError(t, ft.Execute(target.KeyStore{}, target.KeyStore{"a": "s3cr3t"}))
+
+	writtenFileContentsBytes, err := os.ReadFile(ft.Path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(writtenFileContentsBytes), "s3cr3t")
+
+	key, err := encryption.ParseKey(privateKey)
+	assert.NoError(t, err)
+	plaintext, err := encryption.Decrypt(writtenFileContentsBytes, key, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "a=s3cr3t", string(plaintext))
+
+	ft.WriteMode = "merge"
+	assert.EqualError(t, ft.Execute(target.KeyStore{}, target.KeyStore{"a": "s3cr3t"}), "merge write mode isn't supported along with encryption")
+}
+
+func TestFileTargetSuccessWithEncryptionAndBackups(t *testing.T) {
+	publicKey, _, err := encryption.GenerateKey()
+	assert.NoError(t, err)
+
+	ft := FileTarget{
+		Path:       fmt.Sprintf("%s/sample.env", t.TempDir()),
+		Format:     "env",
+		Backups:    3,
+		Encryption: encryption.Settings{Scheme: "nacl", Recipients: publicKey},
+	}
+	assert.NoError(t, ft.Execute(target.KeyStore{}, target.KeyStore{"a": "one"}))
+	written, err := os.ReadFile(ft.Path)
+	assert.NoError(t, err)
+
+	// the same key store isn't written, nor backed up, again even though its ciphertext would differ
+	assert.NoError(t, ft.Execute(target.KeyStore{}, target.KeyStore{"a": "one"}))
+	rewritten, err := os.ReadFile(ft.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, written, rewritten)
+	backups, err := ListBackups(ft.Path, "")
+	assert.NoError(t, err)
+	assert.Empty(t, backups)
+
+	assert.NoError(t, ft.Execute(target.KeyStore{}, target.KeyStore{"a": "two"}))
+	backups, err = ListBackups(ft.Path, "")
+	assert.NoError(t, err)
+	assert.Len(t, backups, 1)
+}
@@ -0,0 +1,217 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/BurntSushi/toml"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+	"gopkg.in/yaml.v3"
+)
+
+func renderKeyStoreToYamlFormat(keyStore target.KeyStore) (string, error) {
+	contentBytes, err := yaml.Marshal(normalize(map[string]interface{}(keyStore)))
+	if err != nil {
+		return "", fmt.Errorf("error occurred while marshalling the YAML out of the key store: %w", err)
+	}
+	return string(contentBytes), nil
+}
+
+func renderKeyStoreToTomlFormat(keyStore target.KeyStore) (string, error) {
+	content := new(bytes.Buffer)
+	encoder := toml.NewEncoder(content)
+	encoder.Indent = ""
+	if err := encoder.Encode(normalize(map[string]interface{}(keyStore))); err != nil {
+		return "", fmt.Errorf("error occurred while marshalling the TOML out of the key store: %w", err)
+	}
+	return content.String(), nil
+}
+
+// renderKeyStoreToIniFormat renders the top-level scalars as global properties and every nested object as a section,
+// deeper nested objects becoming sections named after their dotted path. INI has no notion of lists, hence, they are rendered as JSON.
+func renderKeyStoreToIniFormat(keyStore target.KeyStore) (string, error) {
+	lines := []string{}
+	var renderSection func(name string, values map[string]interface{}) error
+	renderSection = func(name string, values map[string]interface{}) error {
+		keys := sortedKeys(values)
+		sectionLines := []string{}
+		subSections := []string{}
+		for _, key := range keys {
+			if _, ok := values[key].(map[string]interface{}); ok {
+				subSections = append(subSections, key)
+				continue
+			}
+			value, err := iniValue(values[key])
+			if err != nil {
+				return fmt.Errorf("error occurred while rendering the value of the key '%s': %w", key, err)
+			}
+			sectionLines = append(sectionLines, fmt.Sprintf("%s = %s", key, value))
+		}
+		if name != "" && (len(sectionLines) > 0 || len(subSections) == 0) {
+			if len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, fmt.Sprintf("[%s]", name))
+		}
+		lines = append(lines, sectionLines...)
+		for _, key := range subSections {
+			subName := key
+			if name != "" {
+				subName = name + "." + key
+			}
+			if err := renderSection(subName, values[key].(map[string]interface{})); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := renderSection("", normalize(map[string]interface{}(keyStore)).(map[string]interface{})); err != nil {
+		return "", err
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+func iniValue(value interface{}) (string, error) {
+	var raw string
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		raw = v
+	case []interface{}:
+		// JSON is unambiguous on its own, hence, isn't quoted any further
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(encoded), nil
+	default:
+		raw = fmt.Sprint(v)
+	}
+	if raw == "" || strings.ContainsAny(raw, ";#\"\\\n\r") || strings.TrimSpace(raw) != raw {
+		return quoteIniValue(raw), nil
+	}
+	return raw, nil
+}
+
+func quoteIniValue(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// renderKeyStoreToPropertiesFormat renders the key store as Java properties, flattening nested objects into dotted keys
+// and lists into indexed keys (for example, 'servers[0]') which is how Spring binds them.
+func renderKeyStoreToPropertiesFormat(keyStore target.KeyStore) string {
+	lines := []string{}
+	var flatten func(prefix string, value interface{})
+	flatten = func(prefix string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for _, key := range sortedKeys(v) {
+				name := escapePropertiesKey(key)
+				if prefix != "" {
+					name = prefix + "." + name
+				}
+				flatten(name, v[key])
+			}
+		case []interface{}:
+			for i, item := range v {
+				flatten(fmt.Sprintf("%s[%d]", prefix, i), item)
+			}
+		case nil:
+			lines = append(lines, prefix+"=")
+		default:
+			lines = append(lines, prefix+"="+escapePropertiesValue(fmt.Sprint(v)))
+		}
+	}
+	flatten("", normalize(map[string]interface{}(keyStore)))
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func escapePropertiesKey(key string) string {
+	return escapeProperties(key, true)
+}
+
+func escapePropertiesValue(value string) string {
+	return escapeProperties(value, false)
+}
+
+// escapeProperties escapes as per java.util.Properties, keeping the output ASCII-only so that it reads the same in ISO-8859-1 and UTF-8
+func escapeProperties(s string, isKey bool) string {
+	escaped := new(strings.Builder)
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			escaped.WriteString(`\\`)
+		case r == '\n':
+			escaped.WriteString(`\n`)
+		case r == '\r':
+			escaped.WriteString(`\r`)
+		case r == '\t':
+			escaped.WriteString(`\t`)
+		case r == '\f':
+			escaped.WriteString(`\f`)
+		case r == ' ' && (isKey || i == 0):
+			escaped.WriteString(`\ `)
+		case strings.ContainsRune("=:#!", r) && (isKey || i == 0):
+			escaped.WriteRune('\\')
+			escaped.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			for _, unit := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(escaped, `\u%04x`, unit)
+			}
+		default:
+			escaped.WriteRune(r)
+		}
+	}
+	return escaped.String()
+}
+
+// normalize converts the JSON numbers vault hands out into plain numbers, so that every format renders them as numbers
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized[key] = normalize(item)
+		}
+		return normalized
+	case target.KeyStore:
+		return normalize(map[string]interface{}(v))
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalize(item)
+		}
+		return normalized
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	default:
+		return v
+	}
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,125 @@
+package file
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+func nestedKeyStore() target.KeyStore {
+	return target.KeyStore(map[string]interface{}{
+		"name":    "shop",
+		"port":    json.Number("8080"),
+		"ratio":   json.Number("0.5"),
+		"debug":   true,
+		"servers": []interface{}{"a.example.com", "b.example.com"},
+		"database": map[string]interface{}{
+			"user":     "admin",
+			"password": "p@ss #1; \"quoted\"",
+			"pool": map[string]interface{}{
+				"size": json.Number("10"),
+			},
+		},
+	})
+}
+
+func TestRenderKeyStoreToYamlFormat(t *testing.T) {
+	content, err := renderKeyStoreToYamlFormat(nestedKeyStore())
+	assert.NoError(t, err)
+	assert.Equal(t, `database:
+    password: 'p@ss #1; "quoted"'
+    pool:
+        size: 10
+    user: admin
+debug: true
+name: shop
+port: 8080
+ratio: 0.5
+servers:
+    - a.example.com
+    - b.example.com
+`, content)
+}
+
+func TestRenderKeyStoreToTomlFormat(t *testing.T) {
+	content, err := renderKeyStoreToTomlFormat(nestedKeyStore())
+	assert.NoError(t, err)
+	assert.Equal(t, `debug = true
+name = "shop"
+port = 8080
+ratio = 0.5
+servers = ["a.example.com", "b.example.com"]
+
+[database]
+password = "p@ss #1; \"quoted\""
+user = "admin"
+[database.pool]
+size = 10
+`, content)
+}
+
+func TestRenderKeyStoreToIniFormat(t *testing.T) {
+	content, err := renderKeyStoreToIniFormat(nestedKeyStore())
+	assert.NoError(t, err)
+	assert.Equal(t, `debug = true
+name = shop
+port = 8080
+ratio = 0.5
+servers = ["a.example.com","b.example.com"]
+
+[database]
+password = "p@ss #1; \"quoted\""
+user = admin
+
+[database.pool]
+size = 10
+`, content)
+}
+
+func TestRenderKeyStoreToPropertiesFormat(t *testing.T) {
+	keyStore := nestedKeyStore()
+	keyStore["greeting"] = "héllo\nwörld"
+	keyStore["key with=specials"] = " leading space"
+	assert.Equal(t, `database.password=p@ss #1; "quoted"
+database.pool.size=10
+database.user=admin
+debug=true
+greeting=h\u00e9llo\nw\u00f6rld
+key\ with\=specials=\ leading space
+name=shop
+port=8080
+ratio=0.5
+servers[0]=a.example.com
+servers[1]=b.example.com
+`, renderKeyStoreToPropertiesFormat(keyStore))
+}
+
+func TestFileTargetSuccessWithYamlFormat(t *testing.T) {
+	m := mockDir{
+		path: "./test-filetarget",
+	}
+
+	assert.NoDirExists(t, m.path)
+	assert.NoError(t, m.setup())
+	assert.DirExists(t, m.path)
+
+	ft := FileTarget{
+		Path:   fmt.Sprintf("%s/sample.yaml", m.path),
+		Format: "yaml",
+	}
+	newKeyStore := target.KeyStore(map[string]interface{}{
+		"foo": "bar",
+	})
+	assert.NoError(t, ft.Execute(target.KeyStore{}, newKeyStore))
+
+	writtenFileContentsBytes, err := os.ReadFile(ft.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo: bar\n", string(writtenFileContentsBytes))
+
+	assert.NoError(t, m.teardown())
+	assert.NoDirExists(t, m.path)
+}
@@ -0,0 +1,133 @@
+package file
+
+import (
+	"sort"
+	"strings"
+)
+
+type WriteMode string
+
+const (
+	Overwrite WriteMode = "overwrite"
+	Merge     WriteMode = "merge"
+)
+
+const (
+	beginMarker = "# BEGIN vaultie-talkie managed block, do not edit"
+	endMarker   = "# END vaultie-talkie managed block"
+)
+
+// mergeWithMarkerBlock replaces whatever lies between the markers in the existing contents with the rendered contents,
+// appending the marked block to the end of the existing contents if it isn't there yet.
+func mergeWithMarkerBlock(existing, rendered string) string {
+	block := []string{beginMarker}
+	if rendered != "" {
+		block = append(block, strings.Split(strings.TrimSuffix(rendered, "\n"), "\n")...)
+	}
+	block = append(block, endMarker)
+
+	lines := splitLines(existing)
+	begin, end := -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case beginMarker:
+			if begin == -1 {
+				begin = i
+			}
+		case endMarker:
+			if begin != -1 && end == -1 {
+				end = i
+			}
+		}
+	}
+
+	merged := []string{}
+	if begin != -1 && end != -1 {
+		merged = append(merged, lines[:begin]...)
+		merged = append(merged, block...)
+		merged = append(merged, lines[end+1:]...)
+	} else {
+		merged = append(merged, lines...)
+		if len(merged) > 0 && strings.TrimSpace(merged[len(merged)-1]) != "" {
+			merged = append(merged, "")
+		}
+		merged = append(merged, block...)
+	}
+	return strings.Join(merged, "\n") + "\n"
+}
+
+// mergeByKeyPrefix treats every key starting with the prefix as owned by vaultie-talkie: owned keys are updated in place,
+// owned keys which weren't rendered are removed and newly rendered keys are appended. Every other line is left as is.
+func mergeByKeyPrefix(existing, rendered, prefix string, format FileFormat) string {
+	renderedLines := map[string]string{}
+	for _, line := range splitLines(rendered) {
+		if key, ok := lineKey(line, format); ok {
+			renderedLines[key] = line
+		}
+	}
+
+	merged := []string{}
+	written := map[string]bool{}
+	for _, line := range splitLines(existing) {
+		key, ok := lineKey(line, format)
+		if !ok || !strings.HasPrefix(key, prefix) {
+			merged = append(merged, line)
+			continue
+		}
+		if renderedLine, ok := renderedLines[key]; ok && !written[key] {
+			merged = append(merged, renderedLine)
+			written[key] = true
+		}
+	}
+
+	appended := []string{}
+	for key := range renderedLines {
+		if !written[key] {
+			appended = append(appended, key)
+		}
+	}
+	sort.Strings(appended)
+	for _, key := range appended {
+		merged = append(merged, renderedLines[key])
+	}
+	if len(merged) == 0 {
+		return ""
+	}
+	return strings.Join(merged, "\n") + "\n"
+}
+
+func splitLines(content string) []string {
+	if content == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// lineKey returns the key a line of an env or properties file assigns, if any
+func lineKey(line string, format FileFormat) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || (format == Properties && strings.HasPrefix(trimmed, "!")) {
+		return "", false
+	}
+	if format == Env {
+		trimmed = strings.TrimSpace(strings.TrimPrefix(trimmed, "export "))
+		idx := strings.Index(trimmed, "=")
+		if idx <= 0 {
+			return "", false
+		}
+		return strings.TrimSpace(trimmed[:idx]), true
+	}
+	// a properties key ends at the first unescaped '=', ':' or whitespace
+	escaped := false
+	for i, r := range trimmed {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '=' || r == ':' || r == ' ' || r == '\t':
+			return trimmed[:i], true
+		}
+	}
+	return trimmed, true
+}
@@ -0,0 +1,82 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+func TestMergeWithMarkerBlock(t *testing.T) {
+	existing := "# app settings\nLOG_LEVEL=debug\n"
+
+	merged := mergeWithMarkerBlock(existing, "DB_PASSWORD=one\nDB_USER=admin")
+	assert.Equal(t, "# app settings\nLOG_LEVEL=debug\n\n"+beginMarker+"\nDB_PASSWORD=one\nDB_USER=admin\n"+endMarker+"\n", merged)
+
+	// lines around the block, including the ones added after it, are preserved across merges
+	merged += "PORT=8080\n"
+	merged = mergeWithMarkerBlock(merged, "DB_PASSWORD=two")
+	assert.Equal(t, "# app settings\nLOG_LEVEL=debug\n\n"+beginMarker+"\nDB_PASSWORD=two\n"+endMarker+"\nPORT=8080\n", merged)
+
+	assert.Equal(t, beginMarker+"\n"+endMarker+"\n", mergeWithMarkerBlock("", ""))
+}
+
+func TestMergeByKeyPrefix(t *testing.T) {
+	existing := `# managed by ansible
+LOG_LEVEL=debug
+VAULT_DB_PASSWORD=old
+# keep me
+export VAULT_STALE=gone
+PORT=8080
+`
+	merged := mergeByKeyPrefix(existing, "VAULT_API_KEY=abc\nVAULT_DB_PASSWORD=new", "VAULT_", Env)
+	assert.Equal(t, `# managed by ansible
+LOG_LEVEL=debug
+VAULT_DB_PASSWORD=new
+# keep me
+PORT=8080
+VAULT_API_KEY=abc
+`, merged)
+}
+
+func TestMergeByKeyPrefixWithProperties(t *testing.T) {
+	existing := "server.port=8080\n! vault managed\nvault.db.user : old\nvault.removed=x\n"
+	merged := mergeByKeyPrefix(existing, "vault.db.user=admin\n", "vault.", Properties)
+	assert.Equal(t, "server.port=8080\n! vault managed\nvault.db.user=admin\n", merged)
+}
+
+func TestFileTargetSuccessWithMergeWriteMode(t *testing.T) {
+	m := mockDir{
+		path: "./test-filetarget",
+	}
+
+	assert.NoDirExists(t, m.path)
+	assert.NoError(t, m.setup())
+	assert.DirExists(t, m.path)
+
+	ft := FileTarget{
+		Path:        fmt.Sprintf("%s/sample.env", m.path),
+		Format:      "env",
+		EnvSanitize: true,
+		WriteMode:   "merge",
+		MergePrefix: "VAULT_",
+	}
+	assert.NoError(t, os.WriteFile(ft.Path, []byte("# not ours\nPORT=8080\nVAULT_OLD=x\n"), 0600))
+
+	newKeyStore := target.KeyStore(map[string]interface{}{
+		"db.password": "s3cr3t",
+	})
+	assert.NoError(t, ft.Execute(target.KeyStore{}, newKeyStore))
+
+	writtenFileContentsBytes, err := os.ReadFile(ft.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, "# not ours\nPORT=8080\nVAULT_DB_PASSWORD=s3cr3t\n", string(writtenFileContentsBytes))
+
+	ft.Format = "json"
+	assert.EqualError(t, ft.Execute(target.KeyStore{}, newKeyStore), "merge write mode isn't supported for the 'json' format. Currently, it's supported for 'env', 'properties'")
+
+	assert.NoError(t, m.teardown())
+	assert.NoDirExists(t, m.path)
+}
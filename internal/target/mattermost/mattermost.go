@@ -0,0 +1,50 @@
+package mattermost
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/chat"
+)
+
+// Mattermost rejects the posts longer than that, in runes, by default
+const maxTextLength = 16383
+
+type MattermostTarget struct {
+	WebhookUrl      string
+	Channel         string
+	Username        string
+	MessageTemplate string
+	ShowValues      bool
+}
+
+type message struct {
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+	Text     string `json:"text"`
+}
+
+func (m *MattermostTarget) Args() {
+	flag.StringVar(&m.WebhookUrl, "mattermost-webhook-url", "", "Mattermost incoming-webhook URL to which the summary of a key store change is posted")
+	flag.StringVar(&m.Channel, "mattermost-channel", "", "Channel to post the message in, overriding the default channel of the incoming-webhook")
+	flag.StringVar(&m.Username, "mattermost-username", "vaultie-talkie", "Username with which the message is posted")
+	flag.StringVar(&m.MessageTemplate, "mattermost-message-template", chat.DefaultMessageTemplate, "Go template for the headline of the message. Available fields: .Path, .Version, .Added, .Removed, .Changed")
+	flag.BoolVar(&m.ShowValues, "mattermost-show-values", false, "Include the old and new values of the added/removed/changed keys in the message. By default, only the key names are posted")
+}
+
+func (m MattermostTarget) Execute(oldKeyStore, newKeyStore target.KeyStore) error {
+	return m.ExecuteEvent(target.Event{OldKeyStore: oldKeyStore, NewKeyStore: newKeyStore})
+}
+
+func (m MattermostTarget) ExecuteEvent(event target.Event) error {
+	notification, err := chat.Render(event, m.MessageTemplate, m.ShowValues)
+	if err != nil {
+		return fmt.Errorf("error occurred while rendering the mattermost message: %w", err)
+	}
+	return chat.Post("mattermost", m.WebhookUrl, message{
+		Channel:  m.Channel,
+		Username: m.Username,
+		Text:     chat.Truncate(notification.Markdown(chat.EscapeMrkdwn), maxTextLength),
+	})
+}
@@ -0,0 +1,88 @@
+package mattermost
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+type mockMattermostServer struct {
+	returnResponseStatusCode int
+	mockServer               *httptest.Server
+	receivedMessages         []message
+}
+
+func (m *mockMattermostServer) setup() {
+	m.mockServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := message{}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m.receivedMessages = append(m.receivedMessages, msg)
+		w.WriteHeader(m.returnResponseStatusCode)
+	}))
+}
+
+func (m *mockMattermostServer) teardown() {
+	if m.mockServer != nil {
+		m.mockServer.Close()
+	}
+}
+
+func TestMattermostTargetSuccess(t *testing.T) {
+	m := mockMattermostServer{returnResponseStatusCode: 200}
+	m.setup()
+	defer m.teardown()
+
+	mt := MattermostTarget{
+		WebhookUrl:      m.mockServer.URL,
+		Channel:         "town-square",
+		MessageTemplate: "{{ .Path }} changed",
+		ShowValues:      true,
+	}
+	event := target.Event{
+		Path:        "applications/ecommerce",
+		Version:     1,
+		OldKeyStore: target.KeyStore(map[string]interface{}{"foo": "bar"}),
+		NewKeyStore: target.KeyStore(map[string]interface{}{}),
+	}
+	assert.NoError(t, mt.ExecuteEvent(event))
+	assert.Equal(t, []message{{
+		Channel: "town-square",
+		Text:    "applications/ecommerce changed\n\n**Path:** `applications/ecommerce`\n**Version:** 1\n\n**Removed**\n- `foo`: `bar`",
+	}}, m.receivedMessages)
+}
+
+func TestMattermostTargetEscapesAndTruncatesLongMessages(t *testing.T) {
+	m := mockMattermostServer{returnResponseStatusCode: 200}
+	m.setup()
+	defer m.teardown()
+
+	newKeyStore := target.KeyStore{"<!channel>": "value"}
+	for i := 0; i < 2000; i++ {
+		newKeyStore[fmt.Sprintf("key-%04d", i)] = "value"
+	}
+	mt := MattermostTarget{WebhookUrl: m.mockServer.URL}
+	assert.NoError(t, mt.Execute(nil, newKeyStore))
+	assert.Len(t, m.receivedMessages, 1)
+	text := m.receivedMessages[0].Text
+	assert.Contains(t, text, "**Added**\n- `&lt;!channel&gt;`\n")
+	assert.Len(t, []rune(text), maxTextLength)
+	assert.True(t, strings.HasSuffix(text, "…"))
+}
+
+func TestMattermostTargetFailure(t *testing.T) {
+	m := mockMattermostServer{returnResponseStatusCode: 500}
+	m.setup()
+	defer m.teardown()
+
+	mt := MattermostTarget{WebhookUrl: m.mockServer.URL}
+	assert.Error(t, mt.Execute(nil, target.KeyStore(map[string]interface{}{"foo": "bar"})))
+}
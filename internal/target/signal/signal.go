@@ -0,0 +1,156 @@
+package signal
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+const (
+	procDir   = "/proc"
+	cgroupDir = "/sys/fs/cgroup"
+)
+
+type SignalTarget struct {
+	Signal      string
+	PidFile     string
+	ProcessName string
+	Cgroup      string
+}
+
+func (s *SignalTarget) Args() {
+	flag.StringVar(&s.Signal, "signal", "HUP", "Signal to send to the process whenever the key store change is observed, either by name (HUP, SIGUSR1, ...) or by number")
+	flag.StringVar(&s.PidFile, "signal-pid-file", "", "Path of the PID file holding the PID of the process to signal")
+	flag.StringVar(&s.ProcessName, "signal-process-name", "", "Name of the process(es) to signal, matched against the executable name of every running process (Linux only)")
+	flag.StringVar(&s.Cgroup, "signal-cgroup", "", "cgroup whose processes are all signalled, either as an absolute path or relative to /sys/fs/cgroup (Linux only)")
+}
+
+func (s SignalTarget) Execute(oldKeyStore, newKeyStore target.KeyStore) error {
+	sig, err := parseSignal(s.Signal)
+	if err != nil {
+		return err
+	}
+	pids, err := s.resolvePids()
+	if err != nil {
+		return err
+	}
+	for _, pid := range pids {
+		log.Debugf("Sending the signal %s to the process with the PID %d", s.Signal, pid)
+		if err := sendSignal(pid, sig); err != nil {
+			return fmt.Errorf("error occurred while sending the signal '%s' to the process with the PID %d: %w", s.Signal, pid, err)
+		}
+	}
+	return nil
+}
+
+func (s SignalTarget) resolvePids() ([]int, error) {
+	selectors := 0
+	for _, selector := range []string{s.PidFile, s.ProcessName, s.Cgroup} {
+		if selector != "" {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		return nil, fmt.Errorf("exactly one of the PID file, the process name or the cgroup is expected to be provided, found %d", selectors)
+	}
+
+	switch {
+	case s.PidFile != "":
+		pid, err := readPidFile(s.PidFile)
+		if err != nil {
+			return nil, err
+		}
+		if !processExists(pid) {
+			return nil, fmt.Errorf("process with the PID %d read from the PID file '%s' doesn't exist", pid, s.PidFile)
+		}
+		return []int{pid}, nil
+	case s.ProcessName != "":
+		pids, err := findPidsByName(procDir, s.ProcessName)
+		if err != nil {
+			return nil, err
+		}
+		if len(pids) == 0 {
+			return nil, fmt.Errorf("no running process found with the name '%s'", s.ProcessName)
+		}
+		return pids, nil
+	default:
+		pids, err := findPidsInCgroup(s.Cgroup)
+		if err != nil {
+			return nil, err
+		}
+		if len(pids) == 0 {
+			return nil, fmt.Errorf("no running process found in the cgroup '%s'", s.Cgroup)
+		}
+		return pids, nil
+	}
+}
+
+func readPidFile(path string) (int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("error occurred while reading the PID file '%s': %w", path, err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("PID file '%s' doesn't contain a valid PID: %q", path, strings.TrimSpace(string(content)))
+	}
+	return pid, nil
+}
+
+func findPidsByName(procDir, name string) ([]int, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while listing the running processes under '%s': %w", procDir, err)
+	}
+	pids := []int{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		// the process might have exited in the meantime, so, read errors are just skipped
+		if comm, err := os.ReadFile(filepath.Join(procDir, entry.Name(), "comm")); err == nil && strings.TrimSpace(string(comm)) == name {
+			pids = append(pids, pid)
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join(procDir, entry.Name(), "cmdline"))
+		if err != nil || len(cmdline) == 0 {
+			continue
+		}
+		argv0 := string(bytes.SplitN(cmdline, []byte{0}, 2)[0])
+		if filepath.Base(argv0) == name {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+func findPidsInCgroup(cgroup string) ([]int, error) {
+	path := cgroup
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cgroupDir, path)
+	}
+	content, err := os.ReadFile(filepath.Join(path, "cgroup.procs"))
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while listing the processes of the cgroup '%s': %w", cgroup, err)
+	}
+	pids := []int{}
+	for _, field := range strings.Fields(string(content)) {
+		// 0 and negative PIDs would signal whole process groups, if not every process
+		pid, err := strconv.Atoi(field)
+		if err != nil || pid <= 0 {
+			return nil, fmt.Errorf("cgroup '%s' lists an invalid PID %q", cgroup, field)
+		}
+		if processExists(pid) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
@@ -0,0 +1,142 @@
+//go:build linux
+
+package signal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockProcess struct {
+	dirPath string
+	name    string
+	cmd     *exec.Cmd
+	exited  chan error
+}
+
+// setup starts a long-running process whose executable is named after m.name, so that it can be told apart from every other process on the host
+func (m *mockProcess) setup() error {
+	if err := os.MkdirAll(m.dirPath, os.ModePerm); err != nil {
+		return err
+	}
+	sleepPath, err := exec.LookPath("sleep")
+	if err != nil {
+		return err
+	}
+	sleepBinary, err := os.ReadFile(sleepPath)
+	if err != nil {
+		return err
+	}
+	executable := path.Join(m.dirPath, m.name)
+	if err := os.WriteFile(executable, sleepBinary, 0755); err != nil {
+		return err
+	}
+	m.cmd = exec.Command(executable, "60")
+	if err := m.cmd.Start(); err != nil {
+		return err
+	}
+	m.exited = make(chan error, 1)
+	go func() {
+		m.exited <- m.cmd.Wait()
+	}()
+	return nil
+}
+
+func (m *mockProcess) waitForSignal(t *testing.T, expected syscall.Signal) {
+	select {
+	case err := <-m.exited:
+		exitErr, ok := err.(*exec.ExitError)
+		assert.True(t, ok)
+		status := exitErr.Sys().(syscall.WaitStatus)
+		assert.True(t, status.Signaled())
+		assert.Equal(t, expected, status.Signal())
+	case <-time.After(5 * time.Second):
+		t.Fatal("process wasn't signalled in time")
+	}
+}
+
+func (m *mockProcess) teardown() error {
+	if m.cmd != nil && m.cmd.Process != nil {
+		m.cmd.Process.Kill()
+	}
+	return os.RemoveAll(m.dirPath)
+}
+
+func TestSignalTargetSuccessWithPidFile(t *testing.T) {
+	m := mockProcess{dirPath: "./test-signal", name: "vt-sleeper-pid"}
+	assert.NoError(t, m.setup())
+	defer m.teardown()
+
+	pidFile := path.Join(m.dirPath, "app.pid")
+	assert.NoError(t, os.WriteFile(pidFile, []byte(fmt.Sprintf("%d\n", m.cmd.Process.Pid)), 0644))
+
+	st := SignalTarget{Signal: "SIGTERM", PidFile: pidFile}
+	assert.NoError(t, st.Execute(nil, nil))
+	m.waitForSignal(t, syscall.SIGTERM)
+}
+
+func TestSignalTargetSuccessWithProcessName(t *testing.T) {
+	m := mockProcess{dirPath: "./test-signal", name: "vt-sleeper-name"}
+	assert.NoError(t, m.setup())
+	defer m.teardown()
+
+	st := SignalTarget{Signal: "USR1", ProcessName: m.name}
+	assert.NoError(t, st.Execute(nil, nil))
+	m.waitForSignal(t, syscall.SIGUSR1)
+}
+
+func TestSignalTargetSuccessWithCgroup(t *testing.T) {
+	m := mockProcess{dirPath: "./test-signal", name: "vt-sleeper-cgroup"}
+	assert.NoError(t, m.setup())
+	defer m.teardown()
+
+	cgroup, err := os.MkdirTemp("", "vt-cgroup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(cgroup)
+	assert.NoError(t, os.WriteFile(path.Join(cgroup, "cgroup.procs"), []byte(fmt.Sprintf("%d\n", m.cmd.Process.Pid)), 0644))
+
+	st := SignalTarget{Signal: "15", Cgroup: cgroup}
+	assert.NoError(t, st.Execute(nil, nil))
+	m.waitForSignal(t, syscall.SIGTERM)
+}
+
+func TestSignalTargetFailureWithInvalidCgroupPid(t *testing.T) {
+	cgroup := t.TempDir()
+	for _, pid := range []string{"0", "-1", "init"} {
+		assert.NoError(t, os.WriteFile(path.Join(cgroup, "cgroup.procs"), []byte(pid+"\n"), 0644))
+		st := SignalTarget{Signal: "15", Cgroup: cgroup}
+		assert.EqualError(t, st.Execute(nil, nil), fmt.Sprintf("cgroup '%s' lists an invalid PID %q", cgroup, pid))
+	}
+}
+
+func TestSignalTargetFailureWithDeadProcess(t *testing.T) {
+	dirPath := "./test-signal"
+	assert.NoError(t, os.MkdirAll(dirPath, os.ModePerm))
+	defer os.RemoveAll(dirPath)
+
+	pidFile := path.Join(dirPath, "app.pid")
+	assert.NoError(t, os.WriteFile(pidFile, []byte("99999999"), 0644))
+
+	st := SignalTarget{Signal: "HUP", PidFile: pidFile}
+	assert.EqualError(t, st.Execute(nil, nil), fmt.Sprintf("process with the PID 99999999 read from the PID file '%s' doesn't exist", pidFile))
+}
+
+func TestSignalTargetFailureWithUnknownProcessName(t *testing.T) {
+	st := SignalTarget{Signal: "HUP", ProcessName: "vt-surely-not-running"}
+	assert.EqualError(t, st.Execute(nil, nil), "no running process found with the name 'vt-surely-not-running'")
+}
+
+func TestSignalTargetFailureWithInvalidOptions(t *testing.T) {
+	st := SignalTarget{Signal: "HUP", PidFile: "app.pid", ProcessName: "app"}
+	assert.EqualError(t, st.Execute(nil, nil), "exactly one of the PID file, the process name or the cgroup is expected to be provided, found 2")
+
+	st = SignalTarget{Signal: "GIBBERISH", ProcessName: "app"}
+	assert.EqualError(t, st.Execute(nil, nil), "unknown signal 'GIBBERISH' found")
+}
@@ -0,0 +1,45 @@
+//go:build !windows
+
+package signal
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var signalsByName = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"KILL":  syscall.SIGKILL,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"TERM":  syscall.SIGTERM,
+	"CONT":  syscall.SIGCONT,
+	"STOP":  syscall.SIGSTOP,
+	"WINCH": syscall.SIGWINCH,
+}
+
+func parseSignal(name string) (syscall.Signal, error) {
+	if number, err := strconv.Atoi(name); err == nil && number > 0 {
+		return syscall.Signal(number), nil
+	}
+	sig, ok := signalsByName[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return 0, fmt.Errorf("unknown signal '%s' found", name)
+	}
+	return sig, nil
+}
+
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	// EPERM means that the process exists but belongs to someone else
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func sendSignal(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
@@ -0,0 +1,20 @@
+package signal
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func parseSignal(name string) (syscall.Signal, error) {
+	return 0, fmt.Errorf("signalling processes isn't supported on windows")
+}
+
+func processExists(pid int) bool {
+	_, err := os.FindProcess(pid)
+	return err == nil
+}
+
+func sendSignal(pid int, sig syscall.Signal) error {
+	return fmt.Errorf("signalling processes isn't supported on windows")
+}
@@ -0,0 +1,86 @@
+package slack
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/chat"
+)
+
+// Slack rejects the text of a section block longer than that, in characters
+const maxSectionTextLength = 3000
+
+type SlackTarget struct {
+	WebhookUrl      string
+	Channel         string
+	Username        string
+	MessageTemplate string
+	ShowValues      bool
+}
+
+type textObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type block struct {
+	Type   string       `json:"type"`
+	Text   *textObject  `json:"text,omitempty"`
+	Fields []textObject `json:"fields,omitempty"`
+}
+
+type message struct {
+	Channel  string  `json:"channel,omitempty"`
+	Username string  `json:"username,omitempty"`
+	Text     string  `json:"text"`
+	Blocks   []block `json:"blocks"`
+}
+
+func (s *SlackTarget) Args() {
+	flag.StringVar(&s.WebhookUrl, "slack-webhook-url", "", "Slack incoming-webhook URL to which the summary of a key store change is posted")
+	flag.StringVar(&s.Channel, "slack-channel", "", "Channel to post the message in, overriding the default channel of the incoming-webhook")
+	flag.StringVar(&s.Username, "slack-username", "vaultie-talkie", "Username with which the message is posted")
+	flag.StringVar(&s.MessageTemplate, "slack-message-template", chat.DefaultMessageTemplate, "Go template for the headline of the message. Available fields: .Path, .Version, .Added, .Removed, .Changed")
+	flag.BoolVar(&s.ShowValues, "slack-show-values", false, "Include the old and new values of the added/removed/changed keys in the message. By default, only the key names are posted")
+}
+
+func (s SlackTarget) Execute(oldKeyStore, newKeyStore target.KeyStore) error {
+	return s.ExecuteEvent(target.Event{OldKeyStore: oldKeyStore, NewKeyStore: newKeyStore})
+}
+
+func (s SlackTarget) ExecuteEvent(event target.Event) error {
+	notification, err := chat.Render(event, s.MessageTemplate, s.ShowValues)
+	if err != nil {
+		return fmt.Errorf("error occurred while rendering the slack message: %w", err)
+	}
+	return chat.Post("slack", s.WebhookUrl, s.renderMessage(notification))
+}
+
+func (s SlackTarget) renderMessage(notification chat.Notification) message {
+	blocks := []block{
+		{Type: "section", Text: &textObject{Type: "mrkdwn", Text: chat.Truncate(notification.Headline, maxSectionTextLength)}},
+		{Type: "section", Fields: []textObject{
+			{Type: "mrkdwn", Text: fmt.Sprintf("*Path*\n`%s`", notification.Path)},
+			{Type: "mrkdwn", Text: fmt.Sprintf("*Version*\n%d", notification.Version)},
+		}},
+	}
+	for _, section := range notification.Sections {
+		lines := make([]string, 0, len(section.Changes))
+		for _, change := range section.Changes {
+			lines = append(lines, "• "+change.Format("`", chat.EscapeMrkdwn))
+		}
+		blocks = append(blocks, block{
+			Type: "section",
+			Text: &textObject{Type: "mrkdwn", Text: chat.Truncate(fmt.Sprintf("*%s*\n%s", section.Title, strings.Join(lines, "\n")), maxSectionTextLength)},
+		})
+	}
+
+	return message{
+		Channel:  s.Channel,
+		Username: s.Username,
+		Text:     notification.Headline,
+		Blocks:   blocks,
+	}
+}
@@ -0,0 +1,152 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/chat"
+)
+
+type mockSlackServer struct {
+	returnResponseStatusCode int
+	mockServer               *httptest.Server
+	receivedMessages         []map[string]interface{}
+}
+
+func (m *mockSlackServer) setup() {
+	m.mockServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		msg := map[string]interface{}{}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid_payload"))
+			return
+		}
+		m.receivedMessages = append(m.receivedMessages, msg)
+		w.WriteHeader(m.returnResponseStatusCode)
+		if m.returnResponseStatusCode >= 400 {
+			w.Write([]byte("no_service"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+}
+
+func (m *mockSlackServer) teardown() {
+	if m.mockServer != nil {
+		m.mockServer.Close()
+	}
+}
+
+func sampleEvent() target.Event {
+	return target.Event{
+		Path:    "applications/ecommerce",
+		Version: 4,
+		OldKeyStore: target.KeyStore(map[string]interface{}{
+			"foo":     "bar",
+			"stale":   "old-secret",
+			"changed": "before-secret",
+		}),
+		NewKeyStore: target.KeyStore(map[string]interface{}{
+			"foo":     "bar",
+			"a":       "new-secret",
+			"changed": "after-secret",
+		}),
+	}
+}
+
+func TestSlackTargetSuccess(t *testing.T) {
+	m := mockSlackServer{returnResponseStatusCode: 200}
+	m.setup()
+	defer m.teardown()
+
+	st := SlackTarget{
+		WebhookUrl:      m.mockServer.URL,
+		Channel:         "#secrets",
+		Username:        "vaultie-talkie",
+		MessageTemplate: chat.DefaultMessageTemplate,
+	}
+	assert.NoError(t, st.ExecuteEvent(sampleEvent()))
+	assert.Len(t, m.receivedMessages, 1)
+
+	msg := m.receivedMessages[0]
+	assert.Equal(t, "#secrets", msg["channel"])
+	assert.Equal(t, "vaultie-talkie", msg["username"])
+	assert.Equal(t, "Secret at `applications/ecommerce` changed (version 4): 1 added, 1 removed, 1 changed", msg["text"])
+
+	rawBlocks, err := json.Marshal(msg["blocks"])
+	assert.NoError(t, err)
+	blocks := string(rawBlocks)
+	assert.Contains(t, blocks, "*Added*\\n• `a`")
+	assert.Contains(t, blocks, "*Removed*\\n• `stale`")
+	assert.Contains(t, blocks, "*Changed*\\n• `changed`")
+	for _, secret := range []string{"new-secret", "old-secret", "before-secret", "after-secret"} {
+		assert.NotContains(t, blocks, secret)
+	}
+}
+
+func TestSlackTargetSuccessWithValues(t *testing.T) {
+	m := mockSlackServer{returnResponseStatusCode: 200}
+	m.setup()
+	defer m.teardown()
+
+	st := SlackTarget{
+		WebhookUrl:      m.mockServer.URL,
+		MessageTemplate: "{{ .Path }} rotated",
+		ShowValues:      true,
+	}
+	assert.NoError(t, st.ExecuteEvent(sampleEvent()))
+	assert.Len(t, m.receivedMessages, 1)
+
+	msg := m.receivedMessages[0]
+	assert.Equal(t, "applications/ecommerce rotated", msg["text"])
+	assert.NotContains(t, msg, "channel")
+
+	rawBlocks, err := json.Marshal(msg["blocks"])
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(rawBlocks), "`changed`: `before-secret` → `after-secret`"))
+}
+
+func TestSlackTargetEscapesAndTruncatesSections(t *testing.T) {
+	newKeyStore := target.KeyStore{"<!channel>": "value"}
+	for i := 0; i < 500; i++ {
+		newKeyStore[fmt.Sprintf("key-%03d", i)] = "value"
+	}
+	notification, err := chat.Render(target.Event{NewKeyStore: newKeyStore}, "", false)
+	assert.NoError(t, err)
+	msg := SlackTarget{}.renderMessage(notification)
+	added := msg.Blocks[2].Text.Text
+	assert.True(t, strings.HasPrefix(added, "*Added*\n• `&lt;!channel&gt;`\n• `key-000`"))
+	assert.Len(t, []rune(added), maxSectionTextLength)
+	assert.True(t, strings.HasSuffix(added, "…"))
+}
+
+func TestSlackTargetFailure(t *testing.T) {
+	m := mockSlackServer{returnResponseStatusCode: 404}
+	m.setup()
+	defer m.teardown()
+
+	st := SlackTarget{
+		WebhookUrl: m.mockServer.URL,
+	}
+	assert.EqualError(t, st.ExecuteEvent(sampleEvent()), fmt.Sprintf("slack responded with the status code '%d': no_service", m.returnResponseStatusCode))
+}
+
+func TestSlackTargetFailureWithInvalidTemplate(t *testing.T) {
+	st := SlackTarget{
+		WebhookUrl:      "http://localhost:0",
+		MessageTemplate: "{{ .Path ",
+	}
+	assert.Error(t, st.ExecuteEvent(sampleEvent()))
+}
@@ -1,11 +1,28 @@
 package target
 
+import (
+	"context"
+
+	vault "github.com/hashicorp/vault/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// EventIdHeader carries the ID of the event in the requests made by the targets, for tracing a change end-to-end
+const EventIdHeader = "X-Vaultie-Talkie-Event-Id"
+
 type TargetType string
 
 const (
 	Webhook         TargetType = "webhook"
 	File            TargetType = "file"
 	CommandExecutor TargetType = "command"
+	Slack           TargetType = "slack"
+	Email           TargetType = "email"
+	Teams           TargetType = "teams"
+	Discord         TargetType = "discord"
+	Mattermost      TargetType = "mattermost"
+	Signal          TargetType = "signal"
+	Template        TargetType = "template"
 )
 
 type Target interface {
@@ -14,3 +31,67 @@ type Target interface {
 }
 
 type KeyStore map[string]interface{}
+
+// Event carries everything known about a change observed at a watched vault path.
+type Event struct {
+	// ID identifies the change, staying the same across the retries of executing the target for it
+	ID string
+	// Attempt counts the executions of the target for the change, starting from 1
+	Attempt     int
+	Target      TargetType
+	Path        string
+	Version     int
+	OldKeyStore KeyStore
+	NewKeyStore KeyStore
+	// KeyStores holds the latest known key store of every watched path, this event's NewKeyStore included
+	KeyStores map[string]KeyStore
+	// Context carries the trace of the change to whatever the target calls, see Ctx
+	Context context.Context
+}
+
+// Ctx returns the context of the event, the background context if it has none
+func (e Event) Ctx() context.Context {
+	if e.Context == nil {
+		return context.Background()
+	}
+	return e.Context
+}
+
+// Fields are the log fields every line logged about the event carries
+func (e Event) Fields() log.Fields {
+	fields := log.Fields{}
+	if e.ID != "" {
+		fields["event_id"] = e.ID
+	}
+	if e.Attempt > 0 {
+		fields["attempt"] = e.Attempt
+	}
+	if e.Target != "" {
+		fields["target"] = e.Target
+	}
+	if e.Path != "" {
+		fields["path"] = e.Path
+	}
+	return fields
+}
+
+// EventTarget is implemented by targets which need more than the old and new key stores,
+// for example the path or the version of the secret which changed.
+type EventTarget interface {
+	Target
+	ExecuteEvent(event Event) error
+}
+
+// VaultClientTarget is implemented by targets which talk to vault themselves, for example to encrypt with its transit secrets engine.
+type VaultClientTarget interface {
+	Target
+	SetVaultClient(client *vault.Client)
+}
+
+// Execute hands the event to the target, through ExecuteEvent whenever the target supports it.
+func Execute(tg Target, event Event) error {
+	if et, ok := tg.(EventTarget); ok {
+		return et.ExecuteEvent(event)
+	}
+	return tg.Execute(event.OldKeyStore, event.NewKeyStore)
+}
@@ -0,0 +1,22 @@
+package target
+
+import (
+	"fmt"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventFields(t *testing.T) {
+	event := Event{ID: "5c2a7a3e", Attempt: 2, Target: Webhook, Path: "app/config"}
+	assert.Equal(t, log.Fields{"event_id": "5c2a7a3e", "attempt": 2, "target": Webhook, "path": "app/config"}, event.Fields())
+	assert.Equal(t, log.Fields{}, Event{}.Fields())
+}
+
+func TestIsPermanent(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", PermanentError{Err: fmt.Errorf("bad config")})
+	assert.True(t, IsPermanent(err))
+	assert.EqualError(t, err, "wrapped: bad config")
+	assert.False(t, IsPermanent(fmt.Errorf("timed out")))
+}
@@ -0,0 +1,105 @@
+package teams
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/chat"
+)
+
+// Adaptive Cards don't support inline code, hence, no backticks here unlike chat.DefaultMessageTemplate
+const defaultMessageTemplate = "Secret at '{{ .Path }}' changed (version {{ .Version }}): {{ len .Added }} added, {{ len .Removed }} removed, {{ len .Changed }} changed"
+
+type TeamsTarget struct {
+	WebhookUrl      string
+	MessageTemplate string
+	ShowValues      bool
+}
+
+type fact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type element struct {
+	Type   string `json:"type"`
+	Text   string `json:"text,omitempty"`
+	Wrap   bool   `json:"wrap,omitempty"`
+	Weight string `json:"weight,omitempty"`
+	Size   string `json:"size,omitempty"`
+	Facts  []fact `json:"facts,omitempty"`
+}
+
+type adaptiveCard struct {
+	Schema  string    `json:"$schema"`
+	Type    string    `json:"type"`
+	Version string    `json:"version"`
+	Body    []element `json:"body"`
+}
+
+type attachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+type message struct {
+	Type        string       `json:"type"`
+	Attachments []attachment `json:"attachments"`
+}
+
+func (t *TeamsTarget) Args() {
+	flag.StringVar(&t.WebhookUrl, "teams-webhook-url", "", "Microsoft Teams incoming-webhook URL to which an Adaptive Card summarizing a key store change is posted")
+	flag.StringVar(&t.MessageTemplate, "teams-message-template", defaultMessageTemplate, "Go template for the headline of the card. Available fields: .Path, .Version, .Added, .Removed, .Changed")
+	flag.BoolVar(&t.ShowValues, "teams-show-values", false, "Include the old and new values of the added/removed/changed keys in the card. By default, only the key names are posted")
+}
+
+func (t TeamsTarget) Execute(oldKeyStore, newKeyStore target.KeyStore) error {
+	return t.ExecuteEvent(target.Event{OldKeyStore: oldKeyStore, NewKeyStore: newKeyStore})
+}
+
+func (t TeamsTarget) ExecuteEvent(event target.Event) error {
+	messageTemplate := t.MessageTemplate
+	if messageTemplate == "" {
+		messageTemplate = defaultMessageTemplate
+	}
+	notification, err := chat.Render(event, messageTemplate, t.ShowValues)
+	if err != nil {
+		return fmt.Errorf("error occurred while rendering the teams message: %w", err)
+	}
+	return chat.Post("teams", t.WebhookUrl, renderMessage(notification))
+}
+
+func renderMessage(notification chat.Notification) message {
+	body := []element{
+		{Type: "TextBlock", Text: notification.Headline, Wrap: true, Weight: "Bolder", Size: "Medium"},
+		{Type: "FactSet", Facts: []fact{
+			{Title: "Path", Value: notification.Path},
+			{Title: "Version", Value: fmt.Sprint(notification.Version)},
+		}},
+	}
+	for _, section := range notification.Sections {
+		lines := make([]string, 0, len(section.Changes))
+		for _, change := range section.Changes {
+			lines = append(lines, "- "+change.Format("'", chat.Verbatim))
+		}
+		body = append(body,
+			element{Type: "TextBlock", Text: section.Title, Wrap: true, Weight: "Bolder"},
+			element{Type: "TextBlock", Text: strings.Join(lines, "\n"), Wrap: true},
+		)
+	}
+
+	return message{
+		Type: "message",
+		Attachments: []attachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: adaptiveCard{
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Type:    "AdaptiveCard",
+				Version: "1.4",
+				Body:    body,
+			},
+		}},
+	}
+}
@@ -0,0 +1,88 @@
+package teams
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+type mockTeamsServer struct {
+	returnResponseStatusCode int
+	mockServer               *httptest.Server
+	receivedMessages         []message
+}
+
+func (m *mockTeamsServer) setup() {
+	m.mockServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		msg := message{}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m.receivedMessages = append(m.receivedMessages, msg)
+		w.WriteHeader(m.returnResponseStatusCode)
+		w.Write([]byte("1"))
+	}))
+}
+
+func (m *mockTeamsServer) teardown() {
+	if m.mockServer != nil {
+		m.mockServer.Close()
+	}
+}
+
+func TestTeamsTargetSuccess(t *testing.T) {
+	m := mockTeamsServer{returnResponseStatusCode: 200}
+	m.setup()
+	defer m.teardown()
+
+	tt := TeamsTarget{
+		WebhookUrl:      m.mockServer.URL,
+		MessageTemplate: defaultMessageTemplate,
+	}
+	event := target.Event{
+		Path:        "applications/ecommerce",
+		Version:     3,
+		OldKeyStore: target.KeyStore(map[string]interface{}{"foo": "bar"}),
+		NewKeyStore: target.KeyStore(map[string]interface{}{"foo": "baz-secret", "a": "new-secret"}),
+	}
+	assert.NoError(t, tt.ExecuteEvent(event))
+	assert.Len(t, m.receivedMessages, 1)
+
+	msg := m.receivedMessages[0]
+	assert.Equal(t, "message", msg.Type)
+	assert.Len(t, msg.Attachments, 1)
+	assert.Equal(t, "application/vnd.microsoft.card.adaptive", msg.Attachments[0].ContentType)
+
+	card := msg.Attachments[0].Content
+	assert.Equal(t, "AdaptiveCard", card.Type)
+	assert.Equal(t, []element{
+		{Type: "TextBlock", Text: "Secret at 'applications/ecommerce' changed (version 3): 1 added, 0 removed, 1 changed", Wrap: true, Weight: "Bolder", Size: "Medium"},
+		{Type: "FactSet", Facts: []fact{{Title: "Path", Value: "applications/ecommerce"}, {Title: "Version", Value: "3"}}},
+		{Type: "TextBlock", Text: "Added", Wrap: true, Weight: "Bolder"},
+		{Type: "TextBlock", Text: "- 'a'", Wrap: true},
+		{Type: "TextBlock", Text: "Changed", Wrap: true, Weight: "Bolder"},
+		{Type: "TextBlock", Text: "- 'foo'", Wrap: true},
+	}, card.Body)
+}
+
+func TestTeamsTargetFailure(t *testing.T) {
+	m := mockTeamsServer{returnResponseStatusCode: 400}
+	m.setup()
+	defer m.teardown()
+
+	tt := TeamsTarget{
+		WebhookUrl: m.mockServer.URL,
+	}
+	assert.EqualError(t, tt.Execute(nil, target.KeyStore(map[string]interface{}{"foo": "bar"})), "teams responded with the status code '400': 1")
+}
@@ -0,0 +1,180 @@
+package template
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/fileutil"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/commandexecutor"
+)
+
+type TemplateTarget struct {
+	Templates string
+	FileMode  string
+	Command   string
+	// CommandTimeout is the duration after which the command, along with every process it spawned, gets killed
+	CommandTimeout time.Duration
+}
+
+type templateSpec struct {
+	source      string
+	destination string
+}
+
+type templateData struct {
+	Path    string
+	Version int
+	Secrets map[string]target.KeyStore
+}
+
+func (t *TemplateTarget) Args() {
+	flag.StringVar(&t.Templates, "template-files", "", "Comma-separated list of templates to render, each in the form '<path to the Go template>:<path to the rendered file>'. For example, 'nginx.conf.tmpl:/etc/nginx/nginx.conf,app.properties.tmpl:/app/application.properties'")
+	flag.StringVar(&t.FileMode, "template-file-mode", "0600", "Permissions, in octal, of the rendered files")
+	flag.StringVar(&t.Command, "template-command", "", "Command to execute after the templates are rendered, provided that at least one of the rendered files changed or the previous attempt failed. For example, 'nginx -s reload'")
+	flag.DurationVar(&t.CommandTimeout, "template-command-timeout", 5*time.Minute, "Duration after which the command executed after rendering the templates, along with every process it spawned, gets killed. Set to 0 for no timeout")
+}
+
+func (t TemplateTarget) Execute(oldKeyStore, newKeyStore target.KeyStore) error {
+	return t.ExecuteEvent(target.Event{OldKeyStore: oldKeyStore, NewKeyStore: newKeyStore})
+}
+
+func (t TemplateTarget) ExecuteEvent(event target.Event) error {
+	specs, err := parseTemplateSpecs(t.Templates)
+	if err != nil {
+		return err
+	}
+	mode, err := strconv.ParseUint(t.FileMode, 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid file mode '%s' found, expected an octal number like 0600: %w", t.FileMode, err)
+	}
+
+	data := templateData{
+		Path:    event.Path,
+		Version: event.Version,
+		Secrets: event.KeyStores,
+	}
+	if data.Secrets == nil {
+		data.Secrets = map[string]target.KeyStore{event.Path: event.NewKeyStore}
+	}
+
+	changed := false
+	for _, spec := range specs {
+		rendered, err := render(spec.source, data)
+		if err != nil {
+			return err
+		}
+		existing, err := os.ReadFile(spec.destination)
+		if err == nil && bytes.Equal(existing, rendered) {
+			log.WithFields(event.Fields()).Debugf("Rendered contents of the template '%s' didn't change, skipping writing to '%s'", spec.source, spec.destination)
+			continue
+		}
+		log.WithFields(event.Fields()).Debugf("Writing the rendered template '%s' to '%s'", spec.source, spec.destination)
+		if err := fileutil.WriteAtomic(spec.destination, rendered, fileutil.DefaultWriteOptions(os.FileMode(mode))); err != nil {
+			return fmt.Errorf("error occurred while writing the rendered template '%s' to '%s': %w", spec.source, spec.destination, err)
+		}
+		changed = true
+	}
+
+	// a retry finds the files written by the attempt which failed at the command, hence, it runs the command regardless
+	if t.Command == "" || (!changed && event.Attempt <= 1) {
+		return nil
+	}
+	// run the same way as the command target's, so that a hung command gets killed along with its children
+	executor := commandexecutor.CommandExecutorTarget{Command: t.Command, Timeout: t.CommandTimeout}
+	if err := executor.RunCommand(log.WithFields(event.Fields())); err != nil {
+		return fmt.Errorf("error occurred while executing the command after rendering the templates: %w", err)
+	}
+	return nil
+}
+
+func parseTemplateSpecs(templates string) ([]templateSpec, error) {
+	specs := []templateSpec{}
+	for _, spec := range strings.Split(templates, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		source, destination, ok := strings.Cut(spec, ":")
+		if !ok || source == "" || destination == "" {
+			return nil, fmt.Errorf("invalid template '%s' found, expected it in the form '<source>:<destination>'", spec)
+		}
+		specs = append(specs, templateSpec{source: source, destination: destination})
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no templates found to be provided")
+	}
+	return specs, nil
+}
+
+func render(source string, data templateData) ([]byte, error) {
+	tmpl, err := template.New(filepath.Base(source)).Funcs(funcs(data)).ParseFiles(source)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while parsing the template '%s': %w", source, err)
+	}
+	rendered := new(bytes.Buffer)
+	if err := tmpl.Execute(rendered, data); err != nil {
+		return nil, fmt.Errorf("error occurred while rendering the template '%s': %w", source, err)
+	}
+	return rendered.Bytes(), nil
+}
+
+func funcs(data templateData) template.FuncMap {
+	return template.FuncMap{
+		"secret": func(path, key string) (interface{}, error) {
+			keyStore, ok := data.Secrets[path]
+			if !ok {
+				return nil, fmt.Errorf("no key store found for the path '%s'", path)
+			}
+			value, ok := keyStore[key]
+			if !ok {
+				return nil, fmt.Errorf("no key '%s' found in the key store of the path '%s'", key, path)
+			}
+			return value, nil
+		},
+		"base64": func(value interface{}) string {
+			return base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(value)))
+		},
+		"base64Decode": func(value interface{}) (string, error) {
+			decoded, err := base64.StdEncoding.DecodeString(fmt.Sprint(value))
+			return string(decoded), err
+		},
+		"toJSON": func(value interface{}) (string, error) {
+			encoded, err := json.Marshal(value)
+			return string(encoded), err
+		},
+		"default": func(fallback, value interface{}) interface{} {
+			if isEmpty(value) {
+				return fallback
+			}
+			return value
+		},
+		"env": os.Getenv,
+	}
+}
+
+func isEmpty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Map, reflect.Slice, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
@@ -0,0 +1,196 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+type mockDir struct {
+	path string
+}
+
+func (m mockDir) setup() error {
+	if m.path == "" {
+		return fmt.Errorf("directory path not found to be provided")
+	}
+	return os.MkdirAll(m.path, os.ModePerm)
+}
+
+func (m mockDir) teardown() error {
+	if m.path != "" {
+		return os.RemoveAll(m.path)
+	}
+	return nil
+}
+
+func (m mockDir) writeTemplate(name, contents string) (string, error) {
+	templatePath := path.Join(m.path, name)
+	return templatePath, os.WriteFile(templatePath, []byte(contents), 0644)
+}
+
+func sampleEvent() target.Event {
+	newKeyStore := target.KeyStore(map[string]interface{}{
+		"username": "admin",
+		"password": "s3cr3t",
+		"pool":     map[string]interface{}{"size": 10.0},
+	})
+	return target.Event{
+		Path:        "apps/db",
+		Version:     3,
+		OldKeyStore: target.KeyStore(map[string]interface{}{}),
+		NewKeyStore: newKeyStore,
+		KeyStores: map[string]target.KeyStore{
+			"apps/db":    newKeyStore,
+			"apps/cache": target.KeyStore(map[string]interface{}{"url": "redis://cache:6379"}),
+		},
+	}
+}
+
+func TestTemplateTargetSuccess(t *testing.T) {
+	m := mockDir{path: "./test-templatetarget"}
+	assert.NoError(t, m.setup())
+	defer m.teardown()
+
+	propertiesTemplate, err := m.writeTemplate("application.properties.tmpl", `db.username={{ secret "apps/db" "username" }}
+db.password={{ index .Secrets "apps/db" "password" | base64 }}
+db.pool={{ index .Secrets "apps/db" "pool" | toJSON }}
+db.timeout={{ index .Secrets "apps/db" "timeout" | default "30s" }}
+cache.url={{ secret "apps/cache" "url" }}
+home={{ env "VT_TEMPLATE_TEST_HOME" }}
+version={{ .Version }}
+`)
+	assert.NoError(t, err)
+	os.Setenv("VT_TEMPLATE_TEST_HOME", "/srv/app")
+	defer os.Unsetenv("VT_TEMPLATE_TEST_HOME")
+
+	renderedPath := path.Join(m.path, "application.properties")
+	commandOutputFile := path.Join(m.path, "cmd-output.txt")
+	tt := TemplateTarget{
+		Templates: fmt.Sprintf("%s:%s", propertiesTemplate, renderedPath),
+		FileMode:  "0600",
+		Command:   "echo reloaded >> " + commandOutputFile,
+	}
+	assert.NoError(t, tt.ExecuteEvent(sampleEvent()))
+
+	rendered, err := os.ReadFile(renderedPath)
+	assert.NoError(t, err)
+	assert.Equal(t, `db.username=admin
+db.password=czNjcjN0
+db.pool={"size":10}
+db.timeout=30s
+cache.url=redis://cache:6379
+home=/srv/app
+version=3
+`, string(rendered))
+
+	info, err := os.Stat(renderedPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// rendering the same contents again neither rewrites the file nor re-runs the command
+	assert.NoError(t, tt.ExecuteEvent(sampleEvent()))
+	commandOutput, err := os.ReadFile(commandOutputFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "reloaded\n", string(commandOutput))
+}
+
+func TestTemplateTargetFailureWithMissingSecret(t *testing.T) {
+	m := mockDir{path: "./test-templatetarget"}
+	assert.NoError(t, m.setup())
+	defer m.teardown()
+
+	source, err := m.writeTemplate("config.tmpl", `{{ secret "apps/unknown" "password" }}`)
+	assert.NoError(t, err)
+
+	tt := TemplateTarget{
+		Templates: fmt.Sprintf("%s:%s", source, path.Join(m.path, "config")),
+		FileMode:  "0600",
+	}
+	assert.ErrorContains(t, tt.ExecuteEvent(sampleEvent()), "no key store found for the path 'apps/unknown'")
+	assert.NoFileExists(t, path.Join(m.path, "config"))
+}
+
+func TestTemplateTargetFailureWithFailingCommand(t *testing.T) {
+	m := mockDir{path: "./test-templatetarget"}
+	assert.NoError(t, m.setup())
+	defer m.teardown()
+
+	source, err := m.writeTemplate("config.tmpl", `{{ secret "apps/db" "password" }}`)
+	assert.NoError(t, err)
+
+	tt := TemplateTarget{
+		Templates: fmt.Sprintf("%s:%s", source, path.Join(m.path, "config")),
+		FileMode:  "0600",
+		Command:   "echo boom && exit 3",
+	}
+	assert.EqualError(t, tt.ExecuteEvent(sampleEvent()), "error occurred while executing the command after rendering the templates: error occurred while executing the target command 'echo boom && exit 3': exit status 3, output: boom")
+}
+
+func TestTemplateTargetFailureWithHangingCommand(t *testing.T) {
+	m := mockDir{path: "./test-templatetarget"}
+	assert.NoError(t, m.setup())
+	defer m.teardown()
+
+	source, err := m.writeTemplate("config.tmpl", `{{ secret "apps/db" "password" }}`)
+	assert.NoError(t, err)
+
+	tt := TemplateTarget{
+		Templates:      fmt.Sprintf("%s:%s", source, path.Join(m.path, "config")),
+		FileMode:       "0600",
+		Command:        "sleep 30",
+		CommandTimeout: 200 * time.Millisecond,
+	}
+	start := time.Now()
+	assert.EqualError(t, tt.ExecuteEvent(sampleEvent()), "error occurred while executing the command after rendering the templates: target command 'sleep 30' timed out after 200ms and got killed")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestTemplateTargetRetryAfterFailingCommand(t *testing.T) {
+	m := mockDir{path: "./test-templatetarget"}
+	assert.NoError(t, m.setup())
+	defer m.teardown()
+
+	source, err := m.writeTemplate("config.tmpl", `{{ secret "apps/db" "password" }}`)
+	assert.NoError(t, err)
+
+	// the reload fails until the 'healthy' file shows up
+	tt := TemplateTarget{
+		Templates: fmt.Sprintf("%s:%s", source, path.Join(m.path, "config")),
+		FileMode:  "0600",
+		Command:   fmt.Sprintf("test -f %s && touch %s", path.Join(m.path, "healthy"), path.Join(m.path, "reloaded")),
+	}
+	event := sampleEvent()
+	event.Attempt = 1
+	assert.Error(t, tt.ExecuteEvent(event))
+	contents, err := os.ReadFile(path.Join(m.path, "config"))
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cr3t", string(contents))
+
+	// the retry renders the same files, and still reloads
+	assert.NoError(t, os.WriteFile(path.Join(m.path, "healthy"), nil, 0644))
+	event.Attempt = 2
+	assert.NoError(t, tt.ExecuteEvent(event))
+	_, err = os.Stat(path.Join(m.path, "reloaded"))
+	assert.NoError(t, err)
+
+	// whereas the first attempt of a change which renders the same files doesn't
+	assert.NoError(t, os.Remove(path.Join(m.path, "reloaded")))
+	event.Attempt = 1
+	assert.NoError(t, tt.ExecuteEvent(event))
+	_, err = os.Stat(path.Join(m.path, "reloaded"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTemplateTargetFailureWithInvalidSpec(t *testing.T) {
+	tt := TemplateTarget{Templates: "only-a-source", FileMode: "0600"}
+	assert.EqualError(t, tt.ExecuteEvent(sampleEvent()), "invalid template 'only-a-source' found, expected it in the form '<source>:<destination>'")
+
+	tt = TemplateTarget{Templates: "", FileMode: "0600"}
+	assert.EqualError(t, tt.ExecuteEvent(sampleEvent()), "no templates found to be provided")
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/tracing"
 	"io"
 	"net/http"
 	"time"
@@ -19,24 +20,29 @@ type WebhookTarget struct {
 
 func (w *WebhookTarget) Args() {
 	flag.StringVar(&w.Url, "webhook-url", "", "Webhook URL which against which a POST request is triggered in case of vault-key store changes")
-	flag.StringVar(&w.Url, "webhook-access-token", "", "Access token used to authn/authz vaultie-talkie against the Webhook URL")
+	flag.StringVar(&w.BearerToken, "webhook-access-token", "", "Access token used to authn/authz vaultie-talkie against the Webhook URL")
 }
 
 func (w WebhookTarget) Execute(oldKeyStore, newKeyStore target.KeyStore) error {
+	return w.ExecuteEvent(target.Event{OldKeyStore: oldKeyStore, NewKeyStore: newKeyStore})
+}
+
+func (w WebhookTarget) ExecuteEvent(event target.Event) error {
+	logger := log.WithFields(event.Fields())
 	reqPayload := map[string]target.KeyStore{
-		"old_key_store": oldKeyStore,
-		"new_key_store": newKeyStore,
+		"old_key_store": event.OldKeyStore,
+		"new_key_store": event.NewKeyStore,
 	}
 	reqBody := new(bytes.Buffer)
 	if err := json.NewEncoder(reqBody).Encode(reqPayload); err != nil {
-		return fmt.Errorf("error occurred while marshalling the JSON of the webhook payload %+v: %w", reqPayload, err)
+		return fmt.Errorf("error occurred while marshalling the JSON of the webhook payload: %w", err)
 	}
 
 	httpClient := http.Client{
 		Timeout: 5 * time.Second,
 	}
 
-	req, err := http.NewRequest("POST", w.Url, reqBody)
+	req, err := http.NewRequestWithContext(event.Ctx(), "POST", w.Url, reqBody)
 	if err != nil {
 		return fmt.Errorf("error occurred while bootstrapping a new request for the webhook target: %w", err)
 	}
@@ -44,22 +50,27 @@ func (w WebhookTarget) Execute(oldKeyStore, newKeyStore target.KeyStore) error {
 	if w.BearerToken != "" {
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", w.BearerToken))
 	}
+	if event.ID != "" {
+		req.Header.Set(target.EventIdHeader, event.ID)
+	}
+	tracing.Inject(event.Ctx(), req.Header)
 
-	log.Debugf("Triggering a webhook request at POST %s | Body: %s", w.Url, reqBody)
+	logger.Debugf("Triggering a webhook request at POST %s", w.Url)
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("error occurred while making the request to the webhook target: %w", err)
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode >= 400 {
-		log.Debug("Response was found to be of the status code", resp.StatusCode)
+		logger.Debugf("Response was found to be of the status code %d", resp.StatusCode)
 		respBody, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return fmt.Errorf("request ended up with a client/server-side error with status code '%d': error occurred while reading the response body: %w", resp.StatusCode, err)
 		}
 		return fmt.Errorf("request ended up with a client/server-side error with status code '%d': %s", resp.StatusCode, string(respBody))
 	}
-	log.Debug("Webhook request found to be delivered with status code", resp.StatusCode)
+	logger.Debugf("Webhook request found to be delivered with status code %d", resp.StatusCode)
 	return nil
 
 }
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestWebhookTargetSuccess(t *testing.T) {
@@ -66,6 +68,39 @@ func TestWebhookTargetFailure(t *testing.T) {
 	assert.Nil(t, m.teardown())
 }
 
+func TestWebhookTargetSuccessWithEvent(t *testing.T) {
+	var headers http.Header
+	sv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		w.Write([]byte(`{"success":true}`))
+	}))
+	defer sv.Close()
+
+	wt := WebhookTarget{
+		Url:         sv.URL + "/webhook-3",
+		BearerToken: "t0k3n",
+	}
+	traceId, err := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	assert.NoError(t, err)
+	spanId, err := trace.SpanIDFromHex("b7ad6b7169203331")
+	assert.NoError(t, err)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	assert.NoError(t, wt.ExecuteEvent(target.Event{
+		Context:     ctx,
+		ID:          "5c2a7a3e-event",
+		Attempt:     1,
+		Path:        "app/config",
+		NewKeyStore: target.KeyStore{"a": "b"},
+	}))
+	assert.Equal(t, "Bearer t0k3n", headers.Get("Authorization"))
+	assert.Equal(t, "5c2a7a3e-event", headers.Get(target.EventIdHeader))
+	assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", headers.Get("traceparent"))
+}
+
 type mockWebhookServerOpts struct {
 	port                     int
 	path                     string
@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName         = "vaultie-talkie"
+	instrumentationName = "github.com/yashvardhan-kukreja/vaultie-talkie"
+)
+
+// Setup exports the spans via OTLP over HTTP to the endpoint, like 'localhost:4318', and propagates them
+// in the W3C 'traceparent' header. The returned function flushes the pending spans and stops exporting.
+func Setup(endpoint string, insecure bool) (func(context.Context) error, error) {
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error occurred while setting up the OTLP exporter for the endpoint '%s': %w", endpoint, err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
+
+// Tracer is a no-op unless Setup was called
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Inject adds the 'traceparent' header of the span in the context, if any, to the outgoing request
+func Inject(ctx context.Context, header http.Header) {
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// TraceParent returns the W3C traceparent of the span in the context, for resuming its trace later on, see WithTraceParent
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// WithTraceParent returns a context whose spans join the trace of the traceparent, if any
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": traceParent})
+}
+
+// End ends the span, marking it as failed if there's an error
+func End(span trace.Span, err error, opts ...trace.SpanEndOption) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(opts...)
+}
+
+// Attributes of the spans about a change
+var (
+	PathKey    = attribute.Key("vaultie_talkie.path")
+	EventIdKey = attribute.Key("vaultie_talkie.event_id")
+	AttemptKey = attribute.Key("vaultie_talkie.attempt")
+	VersionKey = attribute.Key("vaultie_talkie.version")
+	TargetKey  = attribute.Key("vaultie_talkie.target")
+)
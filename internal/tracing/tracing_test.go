@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// mockCollector is an in-process stand-in of an OpenTelemetry collector receiving OTLP over HTTP
+type mockCollector struct {
+	server *httptest.Server
+	mu     sync.Mutex
+	spans  map[string]string // span name -> its parent span's name
+}
+
+func (m *mockCollector) setup() {
+	m.spans = map[string]string{}
+	m.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		req := &coltracepb.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		names := map[string]string{}
+		for _, resourceSpans := range req.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				for _, span := range scopeSpans.Spans {
+					names[string(span.SpanId)] = span.Name
+				}
+			}
+		}
+		for _, resourceSpans := range req.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				for _, span := range scopeSpans.Spans {
+					m.spans[span.Name] = names[string(span.ParentSpanId)]
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		resp, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+		w.Write(resp)
+	}))
+}
+
+func (m *mockCollector) teardown() {
+	m.server.Close()
+}
+
+func TestSetupExportsSpans(t *testing.T) {
+	m := mockCollector{}
+	m.setup()
+	defer m.teardown()
+
+	shutdown, err := Setup(strings.TrimPrefix(m.server.URL, "http://"), true)
+	assert.NoError(t, err)
+
+	ctx, parent := Tracer().Start(context.Background(), "change")
+	ctx, child := Tracer().Start(ctx, "target.execute")
+	header := http.Header{}
+	Inject(ctx, header)
+	assert.Regexp(t, "^00-"+child.SpanContext().TraceID().String()+"-"+child.SpanContext().SpanID().String()+"-01$", header.Get("traceparent"))
+	End(child, nil)
+	End(parent, nil)
+
+	assert.NoError(t, shutdown(context.Background()))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	assert.Equal(t, map[string]string{"change": "", "target.execute": "change"}, m.spans)
+}
+
+func TestTraceParent(t *testing.T) {
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "change")
+	defer span.End()
+	traceParent := TraceParent(ctx)
+	assert.Equal(t, "00-"+span.SpanContext().TraceID().String()+"-"+span.SpanContext().SpanID().String()+"-01", traceParent)
+
+	resumed := trace.SpanContextFromContext(WithTraceParent(context.Background(), traceParent))
+	assert.Equal(t, span.SpanContext().TraceID(), resumed.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), resumed.SpanID())
+	assert.True(t, resumed.IsRemote())
+
+	assert.Empty(t, TraceParent(context.Background()))
+	assert.False(t, trace.SpanContextFromContext(WithTraceParent(context.Background(), "")).IsValid())
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/metrics"
+)
+
+// nextPoll returns how long until the next read of the path: its polling interval, or the fast one for a while after
+// a change of it, doubling with every read of it in a row which failed, up to the maximum vault backoff. It's jittered
+// so that many instances, or paths, started together don't keep polling vault in lock-step.
+func (p *Poller) nextPoll(path string) time.Duration {
+	s := p.pathSchedule(path)
+	base := p.interval(path)
+	interval := base
+	if p.FastInterval > 0 && time.Now().Before(s.fastUntil) {
+		interval = p.FastInterval
+	}
+	if s.failures > 0 {
+		for i := 0; i < s.failures && interval < p.VaultBackoffMax; i++ {
+			interval *= 2
+		}
+		if interval > p.VaultBackoffMax && p.VaultBackoffMax > base {
+			interval = p.VaultBackoffMax
+		}
+	}
+	if p.PollingJitter > 0 {
+		interval = time.Duration(float64(interval) * (1 + p.PollingJitter*(2*p.rand.Float64()-1)))
+	}
+	metrics.PollingInterval.WithLabelValues(path).Set(interval.Seconds())
+	return interval
+}
+
+// interval returns the polling interval of the path
+func (p *Poller) interval(path string) time.Duration {
+	if interval, ok := p.Intervals[path]; ok {
+		return interval
+	}
+	return p.Interval
+}
+
+// pathIntervals collects the 'PATH=DURATION' pairs of a repeatable flag
+type pathIntervals map[string]time.Duration
+
+func (i pathIntervals) String() string {
+	pairs := []string{}
+	for path, interval := range i {
+		pairs = append(pairs, path+"="+interval.String())
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (i pathIntervals) Set(value string) error {
+	index := strings.LastIndex(value, "=")
+	if index < 0 {
+		return fmt.Errorf("expected 'PATH=DURATION', found '%s'", value)
+	}
+	interval, err := time.ParseDuration(value[index+1:])
+	if err != nil {
+		return fmt.Errorf("error occurred while parsing the polling interval of the path '%s': %w", value[:index], err)
+	}
+	if interval <= 0 {
+		return fmt.Errorf("polling interval of the path '%s' has to be positive", value[:index])
+	}
+	i[strings.TrimSpace(value[:index])] = interval
+	return nil
+}
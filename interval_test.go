@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextPoll(t *testing.T) {
+	poller := NewPoller(nil, &mockTarget{}, "mock", 5*time.Second, []string{"app", "other"}, -1, 0)
+	poller.PollingJitter = 0
+	poller.VaultBackoffMax = time.Minute
+	assert.Equal(t, 5*time.Second, poller.nextPoll("app"))
+
+	// the reads of a failing path back off on their own
+	for failures, expected := range []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute} {
+		poller.pathSchedule("app").failures = failures
+		assert.Equal(t, expected, poller.nextPoll("app"))
+		assert.Equal(t, 5*time.Second, poller.nextPoll("other"))
+	}
+	poller.pathSchedule("app").failures = 0
+
+	// the fast polling interval lasts for a while after a change of the path
+	poller.FastInterval = time.Second
+	assert.Equal(t, 5*time.Second, poller.nextPoll("app"))
+	poller.pathSchedule("app").fastUntil = time.Now().Add(time.Minute)
+	assert.Equal(t, time.Second, poller.nextPoll("app"))
+	assert.Equal(t, 5*time.Second, poller.nextPoll("other"))
+	poller.pathSchedule("app").fastUntil = time.Now().Add(-time.Second)
+	assert.Equal(t, 5*time.Second, poller.nextPoll("app"))
+
+	// a path can be polled at an interval of its own
+	poller.Intervals = map[string]time.Duration{"other": 2 * time.Minute}
+	assert.Equal(t, 2*time.Minute, poller.nextPoll("other"))
+	poller.pathSchedule("other").failures = 3
+	assert.Equal(t, 2*time.Minute, poller.nextPoll("other"))
+	poller.Intervals = nil
+
+	poller.PollingJitter = 0.1
+	poller.rand = rand.New(rand.NewSource(1))
+	intervals := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		interval := poller.nextPoll("app")
+		assert.GreaterOrEqual(t, interval, 4500*time.Millisecond)
+		assert.LessOrEqual(t, interval, 5500*time.Millisecond)
+		intervals[interval] = true
+	}
+	assert.Greater(t, len(intervals), 50)
+}
+
+func TestPathIntervals(t *testing.T) {
+	intervals := pathIntervals{}
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Var(intervals, "path-polling-interval", "")
+	assert.NoError(t, flags.Parse([]string{"-path-polling-interval", "app=30s", "-path-polling-interval", "team/db=1m"}))
+	assert.Equal(t, pathIntervals{"app": 30 * time.Second, "team/db": time.Minute}, intervals)
+
+	assert.EqualError(t, intervals.Set("app"), "expected 'PATH=DURATION', found 'app'")
+	assert.Error(t, intervals.Set("app=soon"))
+	assert.EqualError(t, intervals.Set("app=0s"), "polling interval of the path 'app' has to be positive")
+}
+
+func TestPollerBacksOffWhileVaultIsDown(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	tg := &mockTarget{}
+	poller := NewPoller(client, tg, "mock", time.Hour, []string{"app", "other"}, -1, 0)
+	poller.VaultBackoffMax = 10 * time.Hour
+	poller.FastInterval = time.Minute
+	poller.PollingJitter = 0
+	defer startPoller(t, poller)()
+
+	// both paths are missing, hence, every read fails
+	assert.NoError(t, poller.Repoll(context.Background()))
+	assert.NoError(t, poller.Repoll(context.Background()))
+	assert.Equal(t, 2, poller.pathSchedule("app").failures)
+	assert.Equal(t, 2, poller.pathSchedule("other").failures)
+
+	// a path getting read successfully stops backing off, the other one carries on
+	mv.put("app", map[string]interface{}{"password": "foo"})
+	assert.NoError(t, poller.Repoll(context.Background()))
+	assert.Equal(t, 0, poller.pathSchedule("app").failures)
+	assert.Equal(t, 3, poller.pathSchedule("other").failures)
+	assert.Len(t, tg.executed(), 1)
+	// and the change speeds the polling of the path up
+	assert.Equal(t, time.Minute, poller.nextPoll("app"))
+	assert.Equal(t, 8*time.Hour, poller.nextPoll("other"))
+}
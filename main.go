@@ -1,43 +1,122 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/metrics"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/outbox"
 	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
 	commandExecutorTarget "github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/commandexecutor"
+	discordTarget "github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/discord"
+	emailTarget "github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/email"
 	fileTarget "github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/file"
+	mattermostTarget "github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/mattermost"
+	signalTarget "github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/signal"
+	slackTarget "github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/slack"
+	teamsTarget "github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/teams"
+	templateTarget "github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/template"
 	webhookTarget "github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/webhook"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/tracing"
 )
 
 type Opts struct {
 	VaultSettings
-	TargetType      string
-	PollingInterval time.Duration
-	FailureLimit    int64
-	DebugMode       bool
+	TargetType          string
+	PollingInterval     time.Duration
+	PathIntervals       pathIntervals
+	PollingConcurrency  int
+	VaultRateLimit      float64
+	PollingJitter       float64
+	FastInterval        time.Duration
+	FastDuration        time.Duration
+	FailureLimit        int64
+	VaultBackoffMax     time.Duration
+	TargetFailurePolicy string
+	TargetFailureBudget int
+	OutboxDir           string
+	DeliveryBackoff     time.Duration
+	DeliveryBackoffMax  time.Duration
+	Debounce            time.Duration
+	DebounceMaxWait     time.Duration
+	DebugMode           bool
+	MetricsAddress      string
+	HealthAddress       string
+	StallThreshold      time.Duration
+	LogFormat           string
+	OtlpEndpoint        string
+	OtlpInsecure        bool
+	AdminAddress        string
 }
 
 var validTargets = map[target.TargetType]target.Target{
 	target.Webhook:         &webhookTarget.WebhookTarget{},
 	target.File:            &fileTarget.FileTarget{},
 	target.CommandExecutor: &commandExecutorTarget.CommandExecutorTarget{},
+	target.Slack:           &slackTarget.SlackTarget{},
+	target.Email:           &emailTarget.EmailTarget{},
+	target.Teams:           &teamsTarget.TeamsTarget{},
+	target.Discord:         &discordTarget.DiscordTarget{},
+	target.Mattermost:      &mattermostTarget.MattermostTarget{},
+	target.Signal:          &signalTarget.SignalTarget{},
+	target.Template:        &templateTarget.TemplateTarget{},
+}
+
+// subcommands run instead of the poller when named as the first argument, like `vaultie-talkie rollback ...`
+var subcommands = map[string]func(args []string) error{
+	"rollback": rollback,
+	"decrypt":  decrypt,
+	"keygen":   keygen,
+
+	"dead-letters": deadLetters,
 }
 
 func main() {
-	opts := Opts{}
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			if err := subcommand(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	opts := Opts{PathIntervals: pathIntervals{}}
 	flag.StringVar(&opts.Host, "vault-host", "", "Host of the vault store backing your secrets")
 	flag.Int64Var(&opts.Port, "vault-port", 8200, "Port at which the vault store is running")
-	flag.StringVar(&opts.PathToWatch, "vault-path", "", "Path of the secret in the vault store to watch")
+	flag.StringVar(&opts.PathToWatch, "vault-path", "", "Path of the secret in the vault store to watch. Multiple paths can be watched by separating them with commas")
 	flag.StringVar(&opts.AccessToken, "vault-access-token", "", "Access token authorizing to read/list the above path")
 	flag.StringVar(&opts.TargetType, "target-type", "", "Type of action to happen upon vault key changes")
-	flag.Int64Var(&opts.FailureLimit, "failure-limit", 3, "Amount of failures/errors the poller should bear in a row. Once the this number is reached, vaultie-talkie would exit. Until then, it's going to just log the errors and retry. For setting no/infinite failure limits, feed the value -1.")
+	flag.Int64Var(&opts.FailureLimit, "failure-limit", -1, "Amount of vault read failures the poller should bear in a row. Once this number is reached, vaultie-talkie would exit. Until then, it's going to just log the errors and retry with backoff. The default -1 sets no/infinite failure limits. Target failures are handled by -target-failure-policy instead")
+	flag.DurationVar(&opts.VaultBackoffMax, "vault-backoff-max", 5*time.Minute, "Maximum duration the reads of a vault path keep getting spaced out for while they fail. The backoff doubles from the polling interval with every failure in a row")
+	flag.StringVar(&opts.TargetFailurePolicy, "target-failure-policy", string(Retry), "What to do with a watched path once the target failed -target-failure-budget times in a row for it. Currently, supported policies are 'retry' (keep retrying the change with backoff, as per -delivery-backoff), 'skip' (give up on the change) and 'halt' (pause the path until it's resumed via the admin API)")
+	flag.IntVar(&opts.TargetFailureBudget, "target-failure-budget", 3, "Amount of failures of the target in a row, per watched path, after which -target-failure-policy applies")
 	flag.DurationVar(&opts.PollingInterval, "polling-interval", 5*time.Second, "Rate at which the vault store gets polled for watching its contents")
+	flag.Var(opts.PathIntervals, "path-polling-interval", "Polling interval, as 'PATH=DURATION', of a watched path polled at another rate than -polling-interval. Can be repeated")
+	flag.IntVar(&opts.PollingConcurrency, "polling-concurrency", 4, "Maximum amount of vault paths read at once")
+	flag.Float64Var(&opts.VaultRateLimit, "vault-rate-limit", 0, "Maximum amount of requests per second made to vault, shared by all the watched paths. No limit if left 0")
+	flag.Float64Var(&opts.PollingJitter, "polling-jitter", 0.1, "Fraction of the polling interval every poll gets randomly delayed or hastened by, so that many instances don't poll vault in lock-step. For example, 0.1 polls every 4.5s to 5.5s with a 5s polling interval. 0 disables the jitter")
+	flag.DurationVar(&opts.FastInterval, "fast-polling-interval", 0, "Polling interval for -fast-polling-duration after a change is observed, as more changes often follow, like during a rotation. Disabled if left 0")
+	flag.DurationVar(&opts.FastDuration, "fast-polling-duration", time.Minute, "Duration the -fast-polling-interval is used for after a change is observed")
 	flag.BoolVar(&opts.DebugMode, "debug", false, "Run vaultie-talkie in debug mode")
+	flag.StringVar(&opts.OutboxDir, "outbox-dir", "", "Directory persisting the changes pending for the target and the dead letters, so that they outlive restarts. The files hold the secrets, hence, they're only accessible by the user running vaultie-talkie. Changes are only kept in memory if left empty")
+	flag.DurationVar(&opts.DeliveryBackoff, "delivery-backoff", 5*time.Second, "Duration before the first retry of executing the target for a change, doubling with every failure in a row")
+	flag.DurationVar(&opts.DeliveryBackoffMax, "delivery-backoff-max", 5*time.Minute, "Maximum duration between the retries of executing the target for a change")
+	flag.DurationVar(&opts.Debounce, "debounce", 0, "Duration a watched path has to stop changing for before its changes get delivered, coalesced into a single change from the first old key store to the last new one. Changes are delivered as they're observed if left 0")
+	flag.DurationVar(&opts.DebounceMaxWait, "debounce-max-wait", 5*time.Minute, "Maximum duration the changes of a path keep getting held back for by -debounce, since the first one, even if it keeps changing")
+	flag.StringVar(&opts.OtlpEndpoint, "otlp-endpoint", "", "Endpoint, like 'localhost:4318', of the OpenTelemetry collector receiving the traces of the detected changes via OTLP over HTTP. Tracing is disabled if left empty")
+	flag.BoolVar(&opts.OtlpInsecure, "otlp-insecure", false, "Export the traces over plain HTTP rather than HTTPS")
+	flag.StringVar(&opts.LogFormat, "log-format", "text", "Format of the logs. Currently, supported formats are 'text' and 'json'")
+	flag.StringVar(&opts.MetricsAddress, "metrics-address", "", "Address, like ':9090', to expose the Prometheus metrics of vaultie-talkie at, under '/metrics'. Metrics aren't exposed if left empty")
+	flag.StringVar(&opts.HealthAddress, "health-address", "", "Address, like ':8080', to expose the '/healthz' and '/readyz' probes of vaultie-talkie at. Can be the same as the metrics address. Probes aren't exposed if left empty")
+	flag.StringVar(&opts.AdminAddress, "admin-address", "", "Loopback address, like 'localhost:8081', or unix socket, like 'unix:/run/vaultie-talkie/admin.sock', to serve the admin API at. The admin API isn't served if left empty")
+	flag.DurationVar(&opts.StallThreshold, "stall-threshold", 10*time.Minute, "Duration without any progress of the poller after which '/healthz' reports it as stalled. At least 3 polling intervals are used")
 	for _, tg := range validTargets {
 		tg.Args()
 	}
@@ -46,27 +125,141 @@ func main() {
 	if opts.DebugMode {
 		log.SetLevel(log.DebugLevel)
 	}
+	switch opts.LogFormat {
+	case "text", "":
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		log.Fatalf("unknown log format '%s' found. Currently, supported formats are 'text', 'json'", opts.LogFormat)
+	}
 
 	tg, ok := validTargets[target.TargetType(opts.TargetType)]
 	if !ok {
-		log.Fatal("unknown target type found")
+		log.WithField("target", opts.TargetType).Fatal("unknown target type found")
+	}
+	paths := opts.Paths()
+	if len(paths) == 0 {
+		log.Fatal("no vault path found to be provided")
+	}
+	failurePolicy, err := ParseFailurePolicy(opts.TargetFailurePolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if opts.TargetFailureBudget < 1 {
+		log.Fatal("target failure budget has to be at least 1")
+	}
+	watched := map[string]bool{}
+	for _, path := range paths {
+		watched[path] = true
+	}
+	for path := range opts.PathIntervals {
+		if !watched[path] {
+			log.Fatalf("polling interval found for the path '%s' which isn't watched", path)
+		}
+	}
+	if opts.PollingConcurrency < 1 {
+		log.Fatal("polling concurrency has to be at least 1")
+	}
+	if opts.VaultRateLimit < 0 {
+		log.Fatal("vault rate limit can't be negative")
+	}
+	if opts.PollingJitter < 0 || opts.PollingJitter >= 1 {
+		log.Fatal("polling jitter has to be at least 0 and less than 1")
+	}
+	if opts.Debounce > 0 && opts.DebounceMaxWait < opts.Debounce {
+		log.Fatal("debounce max wait has to be at least as long as the debounce window")
 	}
 
-	log.Debug("parsed options", opts)
-	log.Debug("parsed target's options", tg)
+	// the options hold the access tokens and passwords, hence, only the harmless ones are logged
+	log.WithFields(log.Fields{
+		"vault_host":       opts.Host,
+		"vault_port":       opts.Port,
+		"paths":            paths,
+		"target":           opts.TargetType,
+		"polling_interval": opts.PollingInterval,
+		"polling_jitter":   opts.PollingJitter,
+		"path_intervals":   opts.PathIntervals.String(),
+		"concurrency":      opts.PollingConcurrency,
+		"rate_limit":       opts.VaultRateLimit,
+		"failure_limit":    opts.FailureLimit,
+		"failure_policy":   failurePolicy,
+		"failure_budget":   opts.TargetFailureBudget,
+	}).Debug("parsed options")
 
 	exit := make(chan os.Signal, 1)
 	signal.Notify(exit, syscall.SIGTERM, syscall.SIGHUP)
 
 	vaultClient, err := opts.InitClient()
 	if err != nil {
-		log.Fatal("failed to initialize the vault client as per the provided parameters: %w", err)
+		log.WithError(err).Fatal("failed to initialize the vault client as per the provided parameters")
+	}
+
+	if vt, ok := tg.(target.VaultClientTarget); ok {
+		vt.SetVaultClient(vaultClient)
+	}
+
+	poller := NewPoller(vaultClient, tg, target.TargetType(opts.TargetType), opts.PollingInterval, paths, opts.FailureLimit, opts.StallThreshold)
+	poller.Intervals = opts.PathIntervals
+	poller.Concurrency = opts.PollingConcurrency
+	poller.RateLimit = opts.VaultRateLimit
+	poller.VaultBackoffMax = opts.VaultBackoffMax
+	poller.TargetFailurePolicy = failurePolicy
+	poller.TargetFailureBudget = opts.TargetFailureBudget
+	poller.DeliveryBackoff = opts.DeliveryBackoff
+	poller.DeliveryBackoffMax = opts.DeliveryBackoffMax
+	poller.Debounce = opts.Debounce
+	poller.PollingJitter = opts.PollingJitter
+	poller.FastInterval = opts.FastInterval
+	poller.FastDuration = opts.FastDuration
+	poller.DebounceMaxWait = opts.DebounceMaxWait
+	// the metric is the only consumer of the token's time to live, hence, vault isn't asked for it unless exposed
+	poller.TokenTTLMetric = opts.MetricsAddress != ""
+	if opts.OutboxDir != "" {
+		if poller.Outbox, err = outbox.NewDir(opts.OutboxDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	handlers := map[string]map[string]http.Handler{}
+	if opts.MetricsAddress != "" {
+		handlers[opts.MetricsAddress] = map[string]http.Handler{"/metrics": metrics.Handler()}
+	}
+	if opts.HealthAddress != "" {
+		if handlers[opts.HealthAddress] == nil {
+			handlers[opts.HealthAddress] = map[string]http.Handler{}
+		}
+		handlers[opts.HealthAddress]["/healthz"] = probeHandler(poller.State.Healthy)
+		handlers[opts.HealthAddress]["/readyz"] = probeHandler(poller.State.Ready)
+	}
+	if err := serveHTTP(handlers); err != nil {
+		log.Fatal(err)
+	}
+	if opts.AdminAddress != "" {
+		listener, err := listenAdmin(opts.AdminAddress)
+		if err != nil {
+			log.Fatal(err)
+		}
+		serve(opts.AdminAddress, listener, adminHandler(poller))
 	}
 
 	log.Debug("vault client setup successfully")
 	log.Debug("starting the poller...")
 
-	if err := Poller(vaultClient, tg, opts.PollingInterval, opts.PathToWatch, exit, opts.FailureLimit); err != nil {
+	shutdownTracing := func(context.Context) error { return nil }
+	if opts.OtlpEndpoint != "" {
+		if shutdownTracing, err = tracing.Setup(opts.OtlpEndpoint, opts.OtlpInsecure); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	err = poller.Run(exit)
+	// the pending spans are flushed even if the poller failed
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		log.WithError(err).Warn("failed to flush the pending traces")
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
@@ -1,57 +1,496 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"os"
 	"reflect"
+	"sync"
 	"time"
 
+	uuid "github.com/hashicorp/go-uuid"
 	vault "github.com/hashicorp/vault/api"
 	log "github.com/sirupsen/logrus"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/metrics"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/outbox"
 	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 )
 
-func Poller(vaultClient *vault.Client, tg target.Target, interval time.Duration, path string, exit chan os.Signal, failureLimit int64) error {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+// how often the remaining time to live of the vault access token is looked up for the metrics
+const tokenTTLRefreshInterval = time.Minute
 
-	oldKeyStore := target.KeyStore{}
-	remainingFailures := failureLimit
+type timespan struct {
+	start, end time.Time
+}
+
+type Poller struct {
+	VaultClient *vault.Client
+	Target      target.Target
+	TargetType  target.TargetType
+	Interval    time.Duration
+	Paths       []string
+	// Intervals overrides the polling interval of some paths
+	Intervals map[string]time.Duration
+	// Concurrency is the maximum amount of paths read at once
+	Concurrency int
+	// RateLimit caps the requests per second made to vault, 0 meaning no limit
+	RateLimit float64
+	// PollingJitter randomizes every polling interval by up to this fraction of it, either way
+	PollingJitter float64
+	// FastInterval is the polling interval for FastDuration after a change is observed, if not 0
+	FastInterval time.Duration
+	FastDuration time.Duration
+	// FailureLimit is the amount of vault read failures in a row borne before exiting, -1 meaning no limit
+	FailureLimit int64
+	// VaultBackoffMax caps how long the reads of a failing path get spaced out
+	VaultBackoffMax time.Duration
+	// TargetFailurePolicy applies to a watcher once the target failed TargetFailureBudget times in a row for it
+	TargetFailurePolicy FailurePolicy
+	TargetFailureBudget int
+	// Outbox queues every detected change until the target gets executed for it, or skipped
+	Outbox outbox.Outbox
+	// DeliveryBackoff is how long the first retry of a change waits, doubling with every failure up to DeliveryBackoffMax
+	DeliveryBackoff    time.Duration
+	DeliveryBackoffMax time.Duration
+	// Debounce holds the changes of a path back until it stops changing for this long, or DebounceMaxWait passes
+	// since the first one, to coalesce them into a single change. 0 disables the debouncing.
+	Debounce        time.Duration
+	DebounceMaxWait time.Duration
+	// TokenTTLMetric has the remaining time to live of the vault access token looked up every minute, for its metric
+	TokenTTLMetric bool
+
+	State *PollerState
+
+	oldKeyStores map[string]target.KeyStore
+	coalescing   map[string]*coalescing
+	schedules    map[string]*pathSchedule
+	// amount of paths handed over to the workers and not read yet
+	reading int
+	limiter *rate.Limiter
+	rand    *rand.Rand
+	// when the next pending change is due, zero if none is
+	nextDelivery time.Time
+	// the event the target got executed for last, of every path, for re-delivering it
+	lastEvents          map[string]target.Event
+	remainingFailures   int64
+	targetFailures      map[string]int
+	tokenTTLRefreshedAt time.Time
+
+	// the admin API asks the poller loop to poll or re-deliver so that it never races with the loop
+	repolls      chan chan error
+	redeliveries chan redelivery
+	// wakes the poller loop up to deliver the changes of a resumed path
+	resumes chan struct{}
+
+	// the delivery pass going on and the one asked for meanwhile, if any
+	pass     *deliveryPass
+	nextPass *deliveryPass
+	// the execution of the target going on in the background, if any, and the re-deliveries waiting for it.
+	// The channel is buffered so that an execution finishing after Run returned never blocks.
+	executing       *execution
+	executions      chan *execution
+	redeliveryQueue []redelivery
+	// the paths the pending re-polls wait for the reads of
+	repolling     map[string]bool
+	repollReplies []chan error
+}
+
+type redelivery struct {
+	path  string
+	reply chan error
+}
+
+var (
+	errUnknownPath = errors.New("path isn't watched")
+	errNoEvent     = errors.New("no event has been delivered for the path yet")
+)
+
+func NewPoller(vaultClient *vault.Client, tg target.Target, targetType target.TargetType, interval time.Duration, paths []string, failureLimit int64, stallThreshold time.Duration) *Poller {
+	// a poll taking a few intervals is no stall
+	if stallThreshold < 3*interval {
+		stallThreshold = 3 * interval
+	}
+	return &Poller{
+		VaultClient:         vaultClient,
+		Target:              tg,
+		TargetType:          targetType,
+		Interval:            interval,
+		Paths:               paths,
+		Concurrency:         4,
+		FailureLimit:        failureLimit,
+		VaultBackoffMax:     5 * time.Minute,
+		TargetFailurePolicy: Retry,
+		TargetFailureBudget: 3,
+		Outbox:              outbox.NewMemory(),
+		DeliveryBackoff:     5 * time.Second,
+		DeliveryBackoffMax:  5 * time.Minute,
+		State:               NewPollerState(paths, failureLimit, stallThreshold),
+		DebounceMaxWait:     5 * time.Minute,
+		PollingJitter:       0.1,
+		FastDuration:        time.Minute,
+		rand:                rand.New(rand.NewSource(time.Now().UnixNano())),
+		oldKeyStores:        map[string]target.KeyStore{},
+		coalescing:          map[string]*coalescing{},
+		lastEvents:          map[string]target.Event{},
+		remainingFailures:   failureLimit,
+		schedules:           map[string]*pathSchedule{},
+		targetFailures:      map[string]int{},
+		repolls:             make(chan chan error),
+		redeliveries:        make(chan redelivery),
+		resumes:             make(chan struct{}, 1),
+		executions:          make(chan *execution, 1),
+		repolling:           map[string]bool{},
+	}
+}
+
+func (p *Poller) Run(exit chan os.Signal) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	workers := sync.WaitGroup{}
+	// the workers are done with by the time Run returns
+	defer workers.Wait()
+	defer cancel()
+	p.limiter = newLimiter(p.RateLimit)
+	jobs := make(chan string, p.Concurrency)
+	results := make(chan readResult)
+	for i := 0; i < p.Concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			p.read(ctx, jobs, results)
+		}()
+	}
+	for _, path := range p.Paths {
+		p.pathSchedule(path).dueAt = time.Now().Add(p.nextPoll(path))
+	}
+
+	polling := time.NewTimer(p.Interval)
+	defer polling.Stop()
+	retry := time.NewTimer(p.Interval)
+	defer retry.Stop()
+	metrics.FailureLimit.Set(float64(p.FailureLimit))
+
+	// the changes left pending by a previous run are delivered right away
+	p.restoreKeyStores()
+	p.deliver(true)
 	for {
+		p.State.heartbeat()
+		p.lookUpTokenTTL()
+		p.dispatch(jobs)
+		resetTimer(polling, p.nextDispatch())
+		// the retries of the pending changes don't wait for the next poll
+		if p.nextDelivery.IsZero() {
+			stopTimer(retry)
+		} else {
+			resetTimer(retry, time.Until(p.nextDelivery))
+		}
+
 		select {
-		case <-ticker.C:
-			newKeyStore, err := renderKeyStore(vaultClient, path)
-			if err != nil {
-				err = fmt.Errorf("error occurred while getting the contents of the key store at the path '%s': %w", path, err)
-				if remainingFailures == 0 {
-					log.Warn("failure limit reached, exiting!")
-					return err
-				}
-				remainingFailures--
-				log.Warn(err.Error())
-				break
-			}
-			if reflect.DeepEqual(oldKeyStore, newKeyStore) {
-				remainingFailures = failureLimit
-				break
-			}
-			log.Debug("change observed between the old and new keystore, proceeding to execute the target")
-			err = tg.Execute(oldKeyStore, newKeyStore)
-			if err != nil {
-				err = fmt.Errorf("error occurred while executing the target: %w", err)
-				if remainingFailures == 0 {
-					log.Warn("failure limit reached, exiting!")
-					return err
-				}
-				remainingFailures--
-				log.Warn(err.Error())
-			} else {
-				remainingFailures = failureLimit
-				oldKeyStore = newKeyStore
+		case <-polling.C:
+		case result := <-results:
+			if err := p.readDone(result); err != nil {
+				p.awaitExecution()
+				p.failRepolls(err)
+				return err
 			}
+		case <-retry.C:
+			p.deliver(false)
+		case x := <-p.executions:
+			p.executed(x)
+			p.next()
+		case reply := <-p.repolls:
+			log.Info("re-polling on demand")
+			p.repoll(reply)
+		case r := <-p.redeliveries:
+			p.redeliveryQueue = append(p.redeliveryQueue, r)
+			p.next()
+		case <-p.resumes:
+			p.schedule(time.Now())
 		case sig := <-exit:
-			log.Infoln("receiving the signal", sig, "Exiting!")
+			log.WithField("signal", sig.String()).Info("received a signal, exiting!")
+			p.awaitExecution()
 			return nil
 		}
 	}
 }
+
+// failRepolls replies with the error to the re-polls still waiting, be it for the reads or the delivery
+func (p *Poller) failRepolls(err error) {
+	replies := p.repollReplies
+	for _, pass := range []*deliveryPass{p.pass, p.nextPass} {
+		if pass != nil {
+			replies = append(replies, pass.replies...)
+		}
+	}
+	for _, reply := range replies {
+		reply <- err
+	}
+}
+
+// stopTimer stops the timer, draining it if it fired already
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
+
+func resetTimer(timer *time.Timer, d time.Duration) {
+	stopTimer(timer)
+	timer.Reset(d)
+}
+
+// Repoll has the poller loop poll every path which isn't paused right away, backing off or not, as well as retry
+// the pending changes, and waits for it to finish
+func (p *Poller) Repoll(ctx context.Context) error {
+	reply := make(chan error, 1)
+	select {
+	case p.repolls <- reply:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Redeliver has the poller loop execute the target again for the event of the path it got executed for last
+func (p *Poller) Redeliver(ctx context.Context, path string) error {
+	if !p.State.watches(path) {
+		return errUnknownPath
+	}
+	reply := make(chan error, 1)
+	select {
+	case p.redeliveries <- redelivery{path: path, reply: reply}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pause stops the path from being read, and hence, its changes from being delivered until it's resumed
+func (p *Poller) Pause(path string) error {
+	if !p.State.watches(path) {
+		return errUnknownPath
+	}
+	p.State.setPaused(path, true)
+	log.WithField("path", path).Info("paused watching the path")
+	return nil
+}
+
+// Resume has the path read again, and its pending changes delivered right away
+func (p *Poller) Resume(path string) error {
+	if !p.State.watches(path) {
+		return errUnknownPath
+	}
+	p.State.setPaused(path, false)
+	metrics.WatchersHalted.WithLabelValues(path).Set(0)
+	log.WithField("path", path).Info("resumed watching the path")
+	// a wake-up already pending does just as well
+	select {
+	case p.resumes <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// fail records a vault read failure and returns the error back only once the failure limit is reached
+func (p *Poller) fail(logger *log.Entry, err error) error {
+	if p.remainingFailures == 0 {
+		logger.WithError(err).Error("failure limit reached, exiting!")
+		return err
+	}
+	p.remainingFailures--
+	metrics.ConsecutiveFailures.Set(float64(p.FailureLimit - p.remainingFailures))
+	p.State.setConsecutiveFailures(p.FailureLimit - p.remainingFailures)
+	logger.Warn(err.Error())
+	return nil
+}
+
+func (p *Poller) succeed() {
+	p.remainingFailures = p.FailureLimit
+	metrics.ConsecutiveFailures.Set(0)
+	p.State.setConsecutiveFailures(0)
+}
+
+// restoreKeyStores picks the key stores up from the newest changes left pending by a previous run, so that the paths
+// aren't detected as changed from an empty key store all over again on top of them
+func (p *Poller) restoreKeyStores() {
+	entries, err := p.Outbox.Pending()
+	if err != nil {
+		log.WithError(err).Warn("failed to list the pending changes for restoring the key stores")
+		return
+	}
+	for _, entry := range entries {
+		if p.State.watches(entry.Path) {
+			p.oldKeyStores[entry.Path] = entry.NewKeyStore
+		}
+	}
+}
+
+// lookUpTokenTTL refreshes the remaining time to live of the vault access token, if it's due and the rate limit allows
+func (p *Poller) lookUpTokenTTL() {
+	if !p.TokenTTLMetric || time.Since(p.tokenTTLRefreshedAt) < tokenTTLRefreshInterval || !p.limiter.Allow() {
+		return
+	}
+	p.tokenTTLRefreshedAt = time.Now()
+	if ttl, err := tokenTTL(p.VaultClient); err != nil {
+		log.WithError(err).Debug("failed to look up the remaining time to live of the vault access token")
+	} else {
+		p.State.setAuthenticated()
+		metrics.TokenTTL.Set(ttl.Seconds())
+	}
+}
+
+// readDone takes in the read of a path by a worker, queues its change if any, and schedules its next read.
+// It returns an error only once the failure limit is reached.
+func (p *Poller) readDone(r readResult) error {
+	s := p.pathSchedule(r.path)
+	s.reading = false
+	p.reading--
+	if p.sharedOutbox() {
+		// picks up the dead letters redriven by another process
+		p.schedule(time.Now())
+	}
+	// the changes are held back until every path got read, then the ones left pending by a previous run go right
+	// away, unless a pending re-poll delivers them anyway
+	if !s.read {
+		s.read = true
+		if p.everyPathRead() && len(p.repollReplies) == 0 {
+			defer p.deliver(true)
+		}
+	}
+	duration := r.read.end.Sub(r.read.start)
+	metrics.Polls.WithLabelValues(r.path).Inc()
+	metrics.PollDuration.WithLabelValues(r.path).Observe(duration.Seconds())
+	logger := log.WithFields(log.Fields{"path": r.path, "duration": duration.String()})
+	if r.err != nil {
+		metrics.PollErrors.WithLabelValues(r.path).Inc()
+		s.failures++
+		retryIn := p.nextPoll(r.path)
+		s.dueAt = time.Now().Add(retryIn)
+		logger = logger.WithField("retry_in", retryIn.String())
+		if err := p.fail(logger, fmt.Errorf("error occurred while getting the contents of the key store at the path '%s': %w", r.path, r.err)); err != nil {
+			return err
+		}
+		p.repolled(r.path)
+		return nil
+	}
+	logger.WithField("version", r.version).Debug("read the key store")
+	s.failures = 0
+	p.succeed()
+	metrics.LastSuccessfulPoll.WithLabelValues(r.path).SetToCurrentTime()
+	p.State.setPolled(r.path)
+	if hash, err := keyStoreHash(r.keyStore); err != nil {
+		logger.WithError(err).Debug("failed to hash the key store")
+	} else {
+		p.State.setKeyStore(r.path, r.version, hash)
+	}
+	p.detect(r)
+	s.dueAt = time.Now().Add(p.nextPoll(r.path))
+	p.repolled(r.path)
+	return nil
+}
+
+// detect queues the change of the key store read, if any, for the target
+func (p *Poller) detect(r readResult) {
+	path, newKeyStore := r.path, r.keyStore
+	oldKeyStore, seen := p.oldKeyStores[path]
+	if !seen {
+		oldKeyStore = target.KeyStore{}
+	}
+	diffStart := time.Now()
+	if reflect.DeepEqual(p.latestKeyStore(path), newKeyStore) {
+		if !seen && p.coalescing[path] == nil {
+			// an empty key store, for the targets to see
+			p.oldKeyStores[path] = newKeyStore
+		}
+		return
+	}
+	p.pathSchedule(path).fastUntil = diffStart.Add(p.FastDuration)
+	entry := outbox.Entry{
+		Path:        path,
+		Version:     r.version,
+		OldKeyStore: oldKeyStore,
+		NewKeyStore: newKeyStore,
+		DetectedAt:  diffStart,
+	}
+	if p.Debounce > 0 {
+		// the old key store only moves on once the coalesced changes get queued, so that they're detected again
+		// rather than lost if vaultie-talkie restarts in the meantime
+		p.coalesce(entry, r.read)
+		metrics.ChangesDetected.WithLabelValues(path).Inc()
+		return
+	}
+	diff := target.ComputeDiff(oldKeyStore, newKeyStore)
+	diffed := timespan{diffStart, time.Now()}
+	logger := log.WithFields(log.Fields{"path": path, "version": entry.Version})
+	if err := p.enqueue(&entry, r.read, diffed, diff); err != nil {
+		// the change gets detected again on the next read
+		logger.WithError(err).Error("failed to queue the change for the target")
+		return
+	}
+	logger.WithField("event_id", entry.ID).Info("change observed between the old and new key store, queued it for the target")
+	metrics.ChangesDetected.WithLabelValues(path).Inc()
+	p.oldKeyStores[path] = newKeyStore
+	p.schedule(time.Now())
+}
+
+// latestKeyStore returns the key store of the path as read last, the changes held back for the debounce window included
+func (p *Poller) latestKeyStore(path string) target.KeyStore {
+	if c, ok := p.coalescing[path]; ok {
+		return c.entry.NewKeyStore
+	}
+	if keyStore, ok := p.oldKeyStores[path]; ok {
+		return keyStore
+	}
+	return target.KeyStore{}
+}
+
+// enqueue queues the change for the target under a new event ID
+func (p *Poller) enqueue(entry *outbox.Entry, read, diffed timespan, diff target.Diff) error {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return fmt.Errorf("error occurred while generating an event ID: %w", err)
+	}
+	entry.ID = id
+	ctx, changeSpan := traceChange(*entry, read, diffed, diff)
+	entry.TraceParent = tracing.TraceParent(ctx)
+	err = p.Outbox.Enqueue(entry)
+	tracing.End(changeSpan, err)
+	return err
+}
+
+// traceChange starts the trace of a change. The spans of the vault read and the diffing are back-dated
+// as they only turn out to be worth tracing once the change is detected.
+func traceChange(entry outbox.Entry, read, diffed timespan, diff target.Diff) (context.Context, trace.Span) {
+	ctx, span := tracing.Tracer().Start(context.Background(), "change", trace.WithTimestamp(read.start), trace.WithAttributes(
+		tracing.PathKey.String(entry.Path),
+		tracing.EventIdKey.String(entry.ID),
+		tracing.VersionKey.Int(entry.Version),
+	))
+	_, readSpan := tracing.Tracer().Start(ctx, "vault.read", trace.WithTimestamp(read.start))
+	readSpan.End(trace.WithTimestamp(read.end))
+	_, diffSpan := tracing.Tracer().Start(ctx, "diff", trace.WithTimestamp(diffed.start), trace.WithAttributes(
+		attribute.Int("vaultie_talkie.diff.added", len(diff.Added)),
+		attribute.Int("vaultie_talkie.diff.removed", len(diff.Removed)),
+		attribute.Int("vaultie_talkie.diff.changed", len(diff.Changed)),
+	))
+	diffSpan.End(trace.WithTimestamp(diffed.end))
+	return ctx, span
+}
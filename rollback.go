@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	fileTarget "github.com/yashvardhan-kukreja/vaultie-talkie/internal/target/file"
+)
+
+// rollback restores the target file of the "file" target-type to one of its backups
+func rollback(args []string) error {
+	flags := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	path := flags.String("target-file-path", "", "Path to the target file to roll back")
+	backupDir := flags.String("target-file-backup-dir", "", "Directory the backups of the target file are kept in. Defaults to '<target file path>.backups'")
+	backup := flags.String("backup", "", "Name of the backup to restore, as shown by -list. Defaults to the newest backup")
+	list := flags.Bool("list", false, "List the backups of the target file, oldest first, instead of restoring one")
+	keep := flags.Int("target-file-backups", 0, "Amount of backups to keep once the contents being replaced are backed up too. Defaults to the amount of backups there are")
+	mode := flags.String("target-file-mode", "0600", "Permissions, in octal, of the restored target file")
+	owner := flags.String("target-file-owner", "", "User, by name or uid, owning the restored target file")
+	group := flags.String("target-file-group", "", "Group, by name or gid, owning the restored target file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return fmt.Errorf("no target file path found to be provided")
+	}
+
+	if *list {
+		backups, err := fileTarget.ListBackups(*path, *backupDir)
+		if err != nil {
+			return err
+		}
+		for _, b := range backups {
+			fmt.Fprintln(os.Stdout, b)
+		}
+		return nil
+	}
+
+	// the restored file gets written exactly the way the "file" target-type writes it
+	opts, err := fileTarget.FileTarget{Mode: *mode, Owner: *owner, Group: *group}.WriteOptions()
+	if err != nil {
+		return err
+	}
+	restored, err := fileTarget.Rollback(*path, *backupDir, *backup, *keep, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "restored %s from the backup %s\n", *path, restored)
+	return nil
+}
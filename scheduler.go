@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+	"golang.org/x/time/rate"
+)
+
+// pathSchedule tells when a path is read next
+type pathSchedule struct {
+	dueAt time.Time
+	// whether a worker is reading the path, in which case it isn't handed over to another one
+	reading bool
+	// vault read failures of the path in a row, backing its reads off
+	failures  int
+	fastUntil time.Time
+	// whether the path got read, successfully or not, since the poller started
+	read bool
+}
+
+// readResult is a read of a path by a worker
+type readResult struct {
+	path     string
+	keyStore target.KeyStore
+	version  int
+	err      error
+	read     timespan
+}
+
+func (p *Poller) pathSchedule(path string) *pathSchedule {
+	s, ok := p.schedules[path]
+	if !ok {
+		s = &pathSchedule{}
+		p.schedules[path] = s
+	}
+	return s
+}
+
+func newLimiter(rateLimit float64) *rate.Limiter {
+	if rateLimit <= 0 {
+		return rate.NewLimiter(rate.Inf, 1)
+	}
+	return rate.NewLimiter(rate.Limit(rateLimit), 1)
+}
+
+// everyPathRead tells whether every path which isn't paused got read since the poller started, as the targets get to
+// see the key stores of all of them
+func (p *Poller) everyPathRead() bool {
+	for _, path := range p.Paths {
+		if !p.pathSchedule(path).read && !p.State.paused(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// read is a worker reading the paths handed over to it, within the rate limit, until the context is done
+func (p *Poller) read(ctx context.Context, jobs <-chan string, results chan<- readResult) {
+	for {
+		var path string
+		select {
+		case path = <-jobs:
+		case <-ctx.Done():
+			return
+		}
+		if err := p.limiter.Wait(ctx); err != nil {
+			return
+		}
+		start := time.Now()
+		keyStore, version, err := renderKeyStore(p.VaultClient, path)
+		result := readResult{path: path, keyStore: keyStore, version: version, err: err, read: timespan{start, time.Now()}}
+		select {
+		case results <- result:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// dispatch hands the due paths over to the idle workers, the most overdue first, so that the paths polled often
+// never keep the others waiting
+func (p *Poller) dispatch(jobs chan<- string) {
+	for p.reading < p.Concurrency {
+		path, s := p.mostOverdue()
+		if s == nil || time.Now().Before(s.dueAt) {
+			return
+		}
+		if p.State.paused(path) {
+			s.dueAt = time.Now().Add(p.nextPoll(path))
+			p.repolled(path)
+			continue
+		}
+		s.reading = true
+		p.reading++
+		jobs <- path
+	}
+}
+
+// mostOverdue returns the path, not being read already, which is due the earliest
+func (p *Poller) mostOverdue() (string, *pathSchedule) {
+	var (
+		overdue  string
+		schedule *pathSchedule
+	)
+	for _, path := range p.Paths {
+		s := p.pathSchedule(path)
+		if s.reading {
+			continue
+		}
+		if schedule == nil || s.dueAt.Before(schedule.dueAt) {
+			overdue, schedule = path, s
+		}
+	}
+	return overdue, schedule
+}
+
+// nextDispatch returns how long until a path is due and a worker is idle to read it. The loop wakes up at least
+// every polling interval regardless, to keep its heartbeat.
+func (p *Poller) nextDispatch() time.Duration {
+	if p.reading >= p.Concurrency {
+		return p.Interval
+	}
+	_, s := p.mostOverdue()
+	if s == nil {
+		return p.Interval
+	}
+	next := time.Until(s.dueAt)
+	if next > p.Interval {
+		return p.Interval
+	}
+	if next < 0 {
+		return 0
+	}
+	return next
+}
+
+// repoll has every path which isn't paused read right away, backing off or not, and replies once they all are
+func (p *Poller) repoll(reply chan error) {
+	for _, path := range p.Paths {
+		if p.State.paused(path) {
+			continue
+		}
+		p.repolling[path] = true
+		// the read in progress, if any, does
+		if s := p.pathSchedule(path); !s.reading {
+			s.dueAt = time.Time{}
+		}
+	}
+	p.repollReplies = append(p.repollReplies, reply)
+	p.repolled("")
+}
+
+// repolled records that the path got read for the pending re-polls, retrying the pending changes once every path
+// is, and replying to them once that's done
+func (p *Poller) repolled(path string) {
+	delete(p.repolling, path)
+	if len(p.repolling) > 0 || len(p.repollReplies) == 0 {
+		return
+	}
+	p.deliver(true, p.repollReplies...)
+	p.repollReplies = nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
+)
+
+func TestPollerReadsConcurrently(t *testing.T) {
+	mv := &mockVault{delay: 100 * time.Millisecond}
+	client := mv.setup(t)
+	defer mv.teardown()
+	paths := []string{"a", "b", "c", "d", "e", "f"}
+	for _, path := range paths {
+		mv.put(path, map[string]interface{}{"password": path})
+	}
+	tg := &mockTarget{}
+	poller := NewPoller(client, tg, "mock", time.Hour, paths, -1, 0)
+	poller.Concurrency = 3
+	defer startPoller(t, poller)()
+
+	assert.NoError(t, poller.Repoll(context.Background()))
+	assert.ElementsMatch(t, paths, mv.read())
+	assert.Len(t, tg.executed(), len(paths))
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	assert.Equal(t, 3, mv.maxReading)
+}
+
+func TestPollerRateLimit(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	paths := []string{"a", "b", "c", "d", "e"}
+	poller := NewPoller(client, &mockTarget{}, "mock", time.Hour, paths, -1, 0)
+	poller.RateLimit = 20
+	defer startPoller(t, poller)()
+
+	// the first read goes right away, every other one waits for 50ms
+	start := time.Now()
+	assert.NoError(t, poller.Repoll(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), 190*time.Millisecond)
+	assert.ElementsMatch(t, paths, mv.read())
+}
+
+func TestPollerDeliversOnceEveryPathIsRead(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	mv.put("a", map[string]interface{}{"password": "foo"})
+	mv.put("b", map[string]interface{}{"password": "bar"})
+	tg := &mockTarget{}
+	poller := NewPoller(client, tg, "mock", 20*time.Millisecond, []string{"a", "b"}, -1, 0)
+	poller.PollingJitter = 0
+	// "a" is read a few times before "b" is ever
+	poller.Intervals = map[string]time.Duration{"b": 200 * time.Millisecond}
+	defer startPoller(t, poller)()
+
+	assert.Eventually(t, func() bool { return len(tg.executed()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	for _, event := range tg.executed() {
+		assert.Equal(t, map[string]target.KeyStore{
+			"a": {"password": "foo"},
+			"b": {"password": "bar"},
+		}, event.KeyStores)
+	}
+}
+
+func TestPollerTokenTTLLookup(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	mv.put("a", map[string]interface{}{"password": "foo"})
+
+	// the lookup is left out unless the metric is exposed
+	poller := NewPoller(client, &mockTarget{}, "mock", time.Hour, []string{"a"}, -1, 0)
+	stopPoller := startPoller(t, poller)
+	assert.NoError(t, poller.Repoll(context.Background()))
+	stopPoller()
+	mv.mu.Lock()
+	assert.Equal(t, 0, mv.tokenLookups)
+	mv.mu.Unlock()
+
+	poller = NewPoller(client, &mockTarget{}, "mock", time.Hour, []string{"a"}, -1, 0)
+	poller.TokenTTLMetric = true
+	defer startPoller(t, poller)()
+	assert.NoError(t, poller.Repoll(context.Background()))
+	assert.Eventually(t, func() bool {
+		mv.mu.Lock()
+		defer mv.mu.Unlock()
+		return mv.tokenLookups == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestPollerPathIntervals(t *testing.T) {
+	mv := &mockVault{}
+	client := mv.setup(t)
+	defer mv.teardown()
+	mv.put("fast", map[string]interface{}{"password": "foo"})
+	mv.put("slow", map[string]interface{}{"password": "bar"})
+	poller := NewPoller(client, &mockTarget{}, "mock", time.Hour, []string{"fast", "slow"}, -1, 0)
+	poller.Intervals = map[string]time.Duration{"fast": 20 * time.Millisecond}
+	defer startPoller(t, poller)()
+
+	assert.Eventually(t, func() bool { return len(mv.read()) >= 3 }, 5*time.Second, 10*time.Millisecond)
+	assert.NotContains(t, mv.read(), "slow")
+}
+
+func TestDispatchMostOverdueFirst(t *testing.T) {
+	poller := NewPoller(nil, &mockTarget{}, "mock", time.Second, []string{"a", "b", "c", "d"}, -1, 0)
+	poller.Concurrency = 1
+	now := time.Now()
+	poller.pathSchedule("a").dueAt = now.Add(-time.Second)
+	poller.pathSchedule("b").dueAt = now.Add(-3 * time.Second)
+	poller.pathSchedule("c").dueAt = now.Add(time.Hour)
+	poller.pathSchedule("d").dueAt = now.Add(-5 * time.Second)
+	poller.State.setPaused("d", true)
+	jobs := make(chan string, poller.Concurrency)
+
+	// the paused path is only rescheduled
+	poller.dispatch(jobs)
+	assert.Equal(t, "b", <-jobs)
+	assert.True(t, poller.pathSchedule("d").dueAt.After(now))
+	// every worker is busy
+	poller.dispatch(jobs)
+	assert.Empty(t, jobs)
+
+	// a path read again right away doesn't get ahead of the one waiting for longer
+	poller.pathSchedule("b").reading = false
+	poller.pathSchedule("b").dueAt = now
+	poller.reading--
+	poller.dispatch(jobs)
+	assert.Equal(t, "a", <-jobs)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// serveHTTP serves the handlers, keyed by their address and then by their pattern, in the background.
+// Handlers sharing an address are served by the same listener.
+func serveHTTP(handlers map[string]map[string]http.Handler) error {
+	for address, patterns := range handlers {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return fmt.Errorf("error occurred while listening on the address '%s': %w", address, err)
+		}
+		mux := http.NewServeMux()
+		for pattern, handler := range patterns {
+			mux.Handle(pattern, handler)
+		}
+		serve(address, listener, mux)
+	}
+	return nil
+}
+
+// serve serves the handler on the listener in the background
+func serve(address string, listener net.Listener, handler http.Handler) {
+	go func() {
+		if err := http.Serve(listener, handler); err != nil {
+			log.Errorf("HTTP server at the address '%s' stopped: %s", address, err)
+		}
+	}()
+}
+
+// probeHandler responds with 200 if the check passes, otherwise with 503 and the reason it failed
+func probeHandler(check func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := check(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err.Error())
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/metrics"
+)
+
+// PollerState is what the poller exposes about itself for telling whether it's ready and healthy.
+// It's safe for concurrent use.
+type PollerState struct {
+	mu sync.RWMutex
+
+	paths          []string
+	failureLimit   int64
+	stallThreshold time.Duration
+
+	authenticated       bool
+	polled              map[string]bool
+	consecutiveFailures int64
+	lastHeartbeat       time.Time
+
+	statuses map[string]*PathStatus
+}
+
+// PathStatus is what's known about a watched path. The hash stands in for the key store so that its values never get exposed.
+type PathStatus struct {
+	Path    string `json:"path"`
+	Version int    `json:"version"`
+	Hash    string `json:"hash,omitempty"`
+	Paused  bool   `json:"paused"`
+	// Halted tells whether the watcher got paused as the target exhausted its failure budget
+	Halted         bool `json:"halted"`
+	TargetFailures int  `json:"target_failures"`
+	// Queued counts the changes pending for the target
+	Queued int `json:"queued"`
+	// Coalescing counts the changes held back within the debounce window
+	Coalescing   int           `json:"coalescing"`
+	LastPolledAt *time.Time    `json:"last_polled_at,omitempty"`
+	LastResult   *TargetResult `json:"last_result,omitempty"`
+}
+
+// TargetResult is the outcome of the last execution of the target for a path
+type TargetResult struct {
+	EventId  string          `json:"event_id"`
+	Attempt  int             `json:"attempt"`
+	Outcome  metrics.Outcome `json:"outcome"`
+	Error    string          `json:"error,omitempty"`
+	At       time.Time       `json:"at"`
+	Duration string          `json:"duration"`
+}
+
+func NewPollerState(paths []string, failureLimit int64, stallThreshold time.Duration) *PollerState {
+	statuses := map[string]*PathStatus{}
+	for _, path := range paths {
+		statuses[path] = &PathStatus{Path: path}
+	}
+	return &PollerState{
+		paths:          paths,
+		failureLimit:   failureLimit,
+		stallThreshold: stallThreshold,
+		polled:         map[string]bool{},
+		lastHeartbeat:  time.Now(),
+		statuses:       statuses,
+	}
+}
+
+func (s *PollerState) setAuthenticated() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authenticated = true
+}
+
+// setPolled records the first successful read of the path, which also proves that the vault client is authenticated
+func (s *PollerState) setPolled(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authenticated = true
+	s.polled[path] = true
+	now := time.Now()
+	s.statuses[path].LastPolledAt = &now
+}
+
+// setKeyStore records the version and the hash of the key store last read at the path
+func (s *PollerState) setKeyStore(path string, version int, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[path].Version = version
+	s.statuses[path].Hash = hash
+}
+
+func (s *PollerState) setResult(path string, result TargetResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[path].LastResult = &result
+}
+
+// setPaused pauses or resumes the path, a resumed path is no longer halted either
+func (s *PollerState) setPaused(path string, paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[path].Paused = paused
+	if !paused {
+		s.statuses[path].Halted = false
+	}
+}
+
+func (s *PollerState) halt(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[path].Paused = true
+	s.statuses[path].Halted = true
+}
+
+func (s *PollerState) setQueued(path string, queued int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[path].Queued = queued
+}
+
+func (s *PollerState) setCoalescing(path string, changes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[path].Coalescing = changes
+}
+
+func (s *PollerState) setTargetFailures(path string, failures int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[path].TargetFailures = failures
+}
+
+func (s *PollerState) paused(path string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.statuses[path].Paused
+}
+
+// watches tells whether the path is one of the watched ones
+func (s *PollerState) watches(path string) bool {
+	_, ok := s.statuses[path]
+	return ok
+}
+
+// Statuses returns a copy of the status of every watched path, in the order they're watched
+func (s *PollerState) Statuses() []PathStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	statuses := make([]PathStatus, 0, len(s.paths))
+	for _, path := range s.paths {
+		status := *s.statuses[path]
+		if status.LastResult != nil {
+			result := *status.LastResult
+			status.LastResult = &result
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func (s *PollerState) setConsecutiveFailures(failures int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.consecutiveFailures = failures
+}
+
+// heartbeat records that the poller loop is making progress
+func (s *PollerState) heartbeat() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastHeartbeat = time.Now()
+}
+
+// Ready returns why the poller isn't ready yet, or nil once the vault client authenticated and every path got read at least once
+func (s *PollerState) Ready() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.authenticated {
+		return fmt.Errorf("vault client hasn't authenticated yet")
+	}
+	pending := []string{}
+	for _, path := range s.paths {
+		if !s.polled[path] {
+			pending = append(pending, path)
+		}
+	}
+	if len(pending) > 0 {
+		sort.Strings(pending)
+		return fmt.Errorf("paths %v haven't been read successfully yet", pending)
+	}
+	return nil
+}
+
+// Healthy returns why the poller is unhealthy, that is, one more failure away from the failure limit or stalled, or nil
+func (s *PollerState) Healthy() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// a limit of 0 exits upon the very first failure, there's no failure to be one away from
+	if s.failureLimit > 0 && s.consecutiveFailures >= s.failureLimit {
+		return fmt.Errorf("%d failures in a row, one more failure reaches the failure limit", s.consecutiveFailures)
+	}
+	if since := time.Since(s.lastHeartbeat); since > s.stallThreshold {
+		return fmt.Errorf("poller hasn't made any progress for %s", since.Round(time.Second))
+	}
+	return nil
+}
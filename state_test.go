@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollerStateReady(t *testing.T) {
+	state := NewPollerState([]string{"app/b", "app/a"}, 3, time.Minute)
+	assert.EqualError(t, state.Ready(), "vault client hasn't authenticated yet")
+
+	state.setAuthenticated()
+	assert.EqualError(t, state.Ready(), "paths [app/a app/b] haven't been read successfully yet")
+
+	state.setPolled("app/a")
+	state.setPolled("app/b")
+	assert.NoError(t, state.Ready())
+}
+
+func TestPollerStateHealthy(t *testing.T) {
+	state := NewPollerState([]string{"app"}, 3, time.Minute)
+	assert.NoError(t, state.Healthy())
+
+	state.setConsecutiveFailures(2)
+	assert.NoError(t, state.Healthy())
+	state.setConsecutiveFailures(3)
+	assert.EqualError(t, state.Healthy(), "3 failures in a row, one more failure reaches the failure limit")
+	state.setConsecutiveFailures(0)
+
+	state.lastHeartbeat = time.Now().Add(-2 * time.Minute)
+	assert.EqualError(t, state.Healthy(), "poller hasn't made any progress for 2m0s")
+	state.heartbeat()
+	assert.NoError(t, state.Healthy())
+
+	unlimited := NewPollerState([]string{"app"}, -1, time.Minute)
+	unlimited.setConsecutiveFailures(100)
+	assert.NoError(t, unlimited.Healthy())
+
+	zero := NewPollerState([]string{"app"}, 0, time.Minute)
+	assert.NoError(t, zero.Healthy())
+}
+
+func TestProbeHandler(t *testing.T) {
+	state := NewPollerState([]string{"app"}, 3, time.Minute)
+	sv := httptest.NewServer(probeHandler(state.Ready))
+	defer sv.Close()
+
+	resp, err := http.Get(sv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	state.setPolled("app")
+	resp, err = http.Get(sv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
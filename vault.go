@@ -2,7 +2,12 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	vault "github.com/hashicorp/vault/api"
 	"github.com/yashvardhan-kukreja/vaultie-talkie/internal/target"
@@ -16,6 +21,16 @@ type VaultSettings struct {
 	AccessToken string
 }
 
+func (v VaultSettings) Paths() []string {
+	paths := []string{}
+	for _, path := range strings.Split(v.PathToWatch, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
 func (v VaultSettings) InitClient() (*vault.Client, error) {
 	config := vault.DefaultConfig()
 	config.Address = fmt.Sprintf("http://%s:%d", v.Host, v.Port)
@@ -28,13 +43,36 @@ func (v VaultSettings) InitClient() (*vault.Client, error) {
 	return client, nil
 }
 
-func renderKeyStore(client *vault.Client, path string) (target.KeyStore, error) {
+func renderKeyStore(client *vault.Client, path string) (target.KeyStore, int, error) {
 	secret, err := client.KVv2("secret").Get(context.Background(), path)
 	if err != nil {
-		return nil, fmt.Errorf("error occurred while listing the secret contents at the path '%s': %w", path, err)
+		return nil, 0, fmt.Errorf("error occurred while listing the secret contents at the path '%s': %w", path, err)
 	}
 	if secret == nil || secret.Data == nil {
-		return nil, nil
+		return nil, 0, nil
+	}
+	version := 0
+	if secret.VersionMetadata != nil {
+		version = secret.VersionMetadata.Version
+	}
+	return target.KeyStore(secret.Data), version, nil
+}
+
+// keyStoreHash identifies the contents of the key store without revealing them
+func keyStoreHash(keyStore target.KeyStore) (string, error) {
+	// the keys of maps get marshalled in the sorted order, hence, equal key stores hash the same
+	contents, err := json.Marshal(keyStore)
+	if err != nil {
+		return "", fmt.Errorf("error occurred while marshalling the key store for hashing it: %w", err)
+	}
+	sum := sha256.Sum256(contents)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+func tokenTTL(client *vault.Client) (time.Duration, error) {
+	secret, err := client.Auth().Token().LookupSelf()
+	if err != nil {
+		return 0, fmt.Errorf("error occurred while looking up the vault access token: %w", err)
 	}
-	return target.KeyStore(secret.Data), nil
+	return secret.TokenTTL()
 }